@@ -0,0 +1,417 @@
+// log4cat parses log files written by log4 back into records, filters them
+// and prints them merged in time order:
+//
+//	log4cat -config conf/log4.yaml -appender file -level warn -grep timeout
+//	log4cat -pattern "[%D %T] [%L] (%S) %M" -rotated -f logs/a.log logs/b.log
+//
+// Records are printed as written, multi-line ones decoded to their original
+// text. Lines that do not parse continue the previous record.
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yefy/log4go/ee"
+	"github.com/yefy/log4go/log4"
+	"gopkg.in/yaml.v3"
+)
+
+type options struct {
+	config          string
+	appender        string
+	pattern         string
+	layout          string
+	multiline       string
+	multilineMarker string
+	level           string
+	target          string
+	since           string
+	until           string
+	source          string
+	grep            string
+	rotated         bool
+	follow          bool
+	interval        time.Duration
+}
+
+func main() {
+	opts := options{}
+	flag.StringVar(&opts.config, "config", "", "log4 yaml config to take the appender from")
+	flag.StringVar(&opts.appender, "appender", "", "appender of -config giving pattern, layout, multiline and path")
+	flag.StringVar(&opts.pattern, "pattern", log4.FORMAT_DEFAULT, "pattern of the files")
+	flag.StringVar(&opts.layout, "layout", "", "layout of the files: pattern|json")
+	flag.StringVar(&opts.multiline, "multiline", "", "multiline policy of the files: keep|replace|escape|indent")
+	flag.StringVar(&opts.multilineMarker, "multiline_marker", "", "multiline marker of the files")
+	flag.StringVar(&opts.level, "level", "", "minimum level")
+	flag.StringVar(&opts.target, "target", "", "comma separated targets")
+	flag.StringVar(&opts.since, "since", "", "first time, 2006-01-02[ 15:04:05], RFC3339 or a duration before now")
+	flag.StringVar(&opts.until, "until", "", "last time, same formats as -since")
+	flag.StringVar(&opts.source, "source", "", "substring of the source")
+	flag.StringVar(&opts.grep, "grep", "", "regexp of the message")
+	flag.BoolVar(&opts.rotated, "rotated", false, "read the rotated segments path.* and path-* first, .gz included")
+	flag.BoolVar(&opts.follow, "f", false, "follow the files across rotations")
+	flag.DurationVar(&opts.interval, "interval", 250*time.Millisecond, "poll interval of -f")
+	flag.Parse()
+
+	err := run(opts, flag.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "log4cat: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options, paths []string) error {
+	pattern := opts.pattern
+	layoutOptions := log4.Log4LayoutOptions{Layout: opts.layout}
+	multilinePolicy := opts.multiline
+	multilineMarker := opts.multilineMarker
+	var loggersMultiline *log4.Log4Multiline
+	if len(opts.appender) > 0 {
+		log4Config, err := loadConfig(opts.config)
+		if err != nil {
+			return ee.New(err, "loadConfig")
+		}
+		appender, ok := log4Config.Appenders[opts.appender]
+		if !ok {
+			return ee.New(nil, "not find appender:%v in config:%v", opts.appender, opts.config)
+		}
+		pattern = appender.Pattern
+		layoutOptions = appender.LayoutOptions()
+		if len(multilinePolicy) <= 0 {
+			multilinePolicy = appender.Multiline
+			multilineMarker = appender.MultilineMarker
+		}
+		if len(multilinePolicy) <= 0 {
+			multiline, err := loggerMultiline(log4Config, opts.appender)
+			if err != nil {
+				return ee.New(err, "loggerMultiline")
+			}
+			loggersMultiline = &multiline
+		}
+		if len(paths) <= 0 && len(appender.Path) > 0 {
+			paths = []string{appender.Path}
+		}
+	}
+	if len(paths) <= 0 {
+		return ee.New(nil, "no file, pass paths or -appender with a path")
+	}
+	err := layoutOptions.Check()
+	if err != nil {
+		return ee.New(err, "")
+	}
+	if len(multilinePolicy) <= 0 {
+		multilinePolicy = log4.MultilineReplace
+	}
+	multiline, err := log4.ParseMultiline(multilinePolicy, multilineMarker)
+	if err != nil {
+		return ee.New(err, "")
+	}
+	if loggersMultiline != nil {
+		multiline = *loggersMultiline
+	}
+	if layoutOptions.Layout == log4.LayoutJson {
+		multiline = log4.Log4Multiline{Policy: log4.MultilineKeep}
+	}
+	layout := log4.NewLog4Layout(pattern, layoutOptions)
+
+	filter, err := newFilter(opts)
+	if err != nil {
+		return ee.New(err, "newFilter")
+	}
+
+	sources := make([]*source, 0, len(paths))
+	for _, path := range paths {
+		segments := []string{path}
+		if opts.rotated {
+			segments, err = rotatedSegments(path)
+			if err != nil {
+				return ee.New(err, "rotatedSegments path:%v", path)
+			}
+		}
+		sources = append(sources, &source{
+			path:      path,
+			segments:  segments,
+			layout:    layout,
+			multiline: multiline,
+			follow:    opts.follow,
+		})
+	}
+	defer func() {
+		for _, s := range sources {
+			s.close()
+		}
+	}()
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	err = merge(sources, filter, out)
+	if err != nil {
+		return err
+	}
+	if !opts.follow {
+		return nil
+	}
+
+	for {
+		out.Flush()
+		time.Sleep(opts.interval)
+		err = merge(sources, filter, out)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func loadConfig(configPath string) (*log4.Log4Config, error) {
+	if len(configPath) <= 0 {
+		return nil, ee.New(nil, "-appender needs -config")
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, ee.New(err, "os.ReadFile path:%v", configPath)
+	}
+	log4Config := &log4.Log4Config{}
+	err = yaml.Unmarshal(data, log4Config)
+	if err != nil {
+		return nil, ee.New(err, "yaml.Unmarshal path:%v", configPath)
+	}
+	return log4Config, nil
+}
+
+// loggerMultiline returns the policy the records reaching the appender are
+// written with when the appender sets none: the one of the loggers writing
+// to it, directly, through composites or as additive loggers of root.
+// Loggers disagreeing on it need -multiline.
+func loggerMultiline(log4Config *log4.Log4Config, name string) (log4.Log4Multiline, error) {
+	names := make([]string, 0, len(log4Config.Loggers)+1)
+	loggers := make(map[string]*log4.Log4ConfigLogger, len(log4Config.Loggers)+1)
+	rootWrites := writesTo(log4Config, log4Config.Root.Appenders, name)
+	if rootWrites {
+		names = append(names, "root")
+		loggers["root"] = &log4Config.Root
+	}
+	for loggerName := range log4Config.Loggers {
+		logger := log4Config.Loggers[loggerName]
+		if writesTo(log4Config, logger.Appenders, name) || (logger.Additive && rootWrites) {
+			names = append(names, loggerName)
+			loggers[loggerName] = &logger
+		}
+	}
+	sort.Strings(names)
+
+	multiline, _ := log4.ParseMultiline(log4.MultilineReplace, "")
+	for i, loggerName := range names {
+		loggerMultiline, err := loggers[loggerName].ParseMultiline()
+		if err != nil {
+			return multiline, ee.New(err, "in loggers:%v", loggerName)
+		}
+		if i > 0 && loggerMultiline != multiline {
+			return multiline, ee.New(nil, "loggers:%v and %v write appender:%v with multiline %v and %v, pass -multiline", names[0], loggerName, name, multiline.Policy, loggerMultiline.Policy)
+		}
+		multiline = loggerMultiline
+	}
+	return multiline, nil
+}
+
+// writesTo reports whether appenders reach name, through the children of
+// composites and the fallbacks included.
+func writesTo(log4Config *log4.Log4Config, appenders []string, name string) bool {
+	seen := make(map[string]bool)
+	var visit func(appender string) bool
+	visit = func(appender string) bool {
+		if appender == name {
+			return true
+		}
+		if seen[appender] {
+			return false
+		}
+		seen[appender] = true
+		config := log4Config.Appenders[appender]
+		next := config.Appenders
+		if len(config.Fallback) > 0 {
+			next = append(next[:len(next):len(next)], config.Fallback)
+		}
+		for _, child := range next {
+			if visit(child) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, appender := range appenders {
+		if visit(appender) {
+			return true
+		}
+	}
+	return false
+}
+
+// rotatedSegments returns the rotated segments of path oldest first, then
+// path itself.
+func rotatedSegments(path string) ([]string, error) {
+	var segments []string
+	for _, glob := range []string{path + ".*", path + "-*"} {
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			return nil, ee.New(err, "filepath.Glob glob:%v", glob)
+		}
+		segments = append(segments, matches...)
+	}
+	modTimes := make(map[string]time.Time, len(segments))
+	for _, segment := range segments {
+		stat, err := os.Stat(segment)
+		if err != nil {
+			return nil, ee.New(err, "os.Stat path:%v", segment)
+		}
+		modTimes[segment] = stat.ModTime()
+	}
+	sort.SliceStable(segments, func(i, j int) bool {
+		return modTimes[segments[i]].Before(modTimes[segments[j]])
+	})
+	return append(segments, path), nil
+}
+
+type filter struct {
+	level   log4.Level
+	targets map[string]bool
+	since   time.Time
+	until   time.Time
+	source  string
+	grep    *regexp.Regexp
+}
+
+func newFilter(opts options) (*filter, error) {
+	f := &filter{level: log4.ALL}
+	var err error
+	if len(opts.level) > 0 {
+		f.level, err = log4.LevelNameToLevel(opts.level)
+		if err != nil {
+			return nil, ee.New(err, "-level")
+		}
+	}
+	if len(opts.target) > 0 {
+		f.targets = make(map[string]bool)
+		for _, target := range strings.Split(opts.target, ",") {
+			f.targets[strings.TrimSpace(target)] = true
+		}
+	}
+	f.since, err = parseTime(opts.since)
+	if err != nil {
+		return nil, ee.New(err, "-since")
+	}
+	f.until, err = parseTime(opts.until)
+	if err != nil {
+		return nil, ee.New(err, "-until")
+	}
+	f.source = opts.source
+	if len(opts.grep) > 0 {
+		f.grep, err = regexp.Compile(opts.grep)
+		if err != nil {
+			return nil, ee.New(err, "-grep")
+		}
+	}
+	return f, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if len(value) <= 0 {
+		return time.Time{}, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"} {
+		t, err := time.ParseInLocation(layout, value, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ee.New(nil, "not a time:%v", value)
+}
+
+func (f *filter) match(rec *log4.Log4Record) bool {
+	if f.level != log4.ALL {
+		level, ok := log4.LevelFileNameToLevel(rec.Level)
+		if !ok || level < f.level {
+			return false
+		}
+	}
+	if f.targets != nil && !f.targets[rec.Target] {
+		return false
+	}
+	if !f.since.IsZero() && rec.Created.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && rec.Created.After(f.until) {
+		return false
+	}
+	if len(f.source) > 0 && !strings.Contains(rec.Source, f.source) {
+		return false
+	}
+	if f.grep != nil && !f.grep.MatchString(rec.Message) {
+		return false
+	}
+	return true
+}
+
+// merge prints the records the sources have now in time order.
+func merge(sources []*source, f *filter, out io.Writer) error {
+	h := make(entryHeap, 0, len(sources))
+	for i, s := range sources {
+		e, err := s.next()
+		if err != nil {
+			return err
+		}
+		if e != nil {
+			e.source = i
+			h = append(h, e)
+		}
+	}
+	heap.Init(&h)
+	for len(h) > 0 {
+		e := heap.Pop(&h).(*entry)
+		if f.match(e.rec) {
+			fmt.Fprintln(out, e.text)
+		}
+		next, err := sources[e.source].next()
+		if err != nil {
+			return err
+		}
+		if next != nil {
+			next.source = e.source
+			heap.Push(&h, next)
+		}
+	}
+	return nil
+}
+
+type entry struct {
+	rec    *log4.Log4Record
+	text   string
+	source int
+}
+
+type entryHeap []*entry
+
+func (h entryHeap) Len() int           { return len(h) }
+func (h entryHeap) Less(i, j int) bool { return h[i].rec.Created.Before(h[j].rec.Created) }
+func (h entryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *entryHeap) Push(x any)        { *h = append(*h, x.(*entry)) }
+func (h *entryHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/yefy/log4go/log4"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLoggerMultiline(t *testing.T) {
+	const config = `
+appenders:
+  file:
+    kind: file
+    path: a.log
+  other:
+    kind: file
+    path: b.log
+  both:
+    kind: tee
+    appenders: [file, other]
+root:
+  level: info
+  multiline: escape
+  appenders: [file]
+loggers:
+  db:
+    level: info
+    multiline: escape
+    additive: true
+    appenders: []
+  net:
+    level: info
+    multiline: indent
+    appenders: [other]
+  tee:
+    level: info
+    multiline: keep
+    appenders: [both]
+`
+	log4Config := &log4.Log4Config{}
+	err := yaml.Unmarshal([]byte(config), log4Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	multiline, err := loggerMultiline(log4Config, "other")
+	if err == nil {
+		t.Fatalf("net and tee disagree on other, got %+v", multiline)
+	}
+
+	delete(log4Config.Loggers, "tee")
+	multiline, err = loggerMultiline(log4Config, "other")
+	if err != nil || multiline.Policy != log4.MultilineIndent {
+		t.Fatalf("other: %+v, err:%v", multiline, err)
+	}
+	multiline, err = loggerMultiline(log4Config, "file")
+	if err != nil || multiline.Policy != log4.MultilineEscape {
+		t.Fatalf("file: %+v, err:%v", multiline, err)
+	}
+
+	// db is additive, root writes file
+	db := log4Config.Loggers["db"]
+	db.MultilinePolicy = log4.MultilineKeep
+	log4Config.Loggers["db"] = db
+	_, err = loggerMultiline(log4Config, "file")
+	if err == nil {
+		t.Fatal("additive db disagrees with root on file")
+	}
+}
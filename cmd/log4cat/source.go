@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/yefy/log4go/ee"
+	"github.com/yefy/log4go/log4"
+)
+
+// source reads the records of one log, its rotated segments first. When
+// following, the last segment is kept open and reopened once the path points
+// to a new file.
+type source struct {
+	path      string
+	segments  []string
+	layout    *log4.Log4Layout
+	multiline log4.Log4Multiline
+	follow    bool
+
+	file    *os.File
+	stat    os.FileInfo
+	gz      *gzip.Reader
+	reader  *bufio.Reader
+	offset  int64
+	partial string
+	pending *entry
+	// pendingIdle counts the calls of next without a line since pending was
+	// last changed
+	pendingIdle int
+}
+
+// next returns the next complete record, nil when there is none for now.
+// A record is complete once the following one starts, or at the end of the
+// data when not following or when no line arrived for a whole poll.
+func (s *source) next() (*entry, error) {
+	for {
+		line, ok, err := s.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		s.pendingIdle = 0
+
+		if s.multiline.Policy == log4.MultilineIndent && s.pending != nil && strings.HasPrefix(line, s.multiline.Marker) {
+			s.pending.append(line[len(s.multiline.Marker):])
+			continue
+		}
+		text := s.multiline.Decode(line)
+		rec, err := s.layout.Parse(text)
+		if err != nil {
+			if s.pending != nil {
+				s.pending.append(text)
+				continue
+			}
+			rec = &log4.Log4Record{Message: text}
+		}
+		prev := s.pending
+		s.pending = &entry{rec: rec, text: text}
+		if prev != nil {
+			return prev, nil
+		}
+	}
+
+	if s.pending == nil {
+		return nil, nil
+	}
+	if s.follow && s.pendingIdle < 2 {
+		s.pendingIdle++
+		return nil, nil
+	}
+	prev := s.pending
+	s.pending = nil
+	return prev, nil
+}
+
+// append adds a continuation line to the record.
+func (e *entry) append(line string) {
+	e.text += "\n" + line
+	e.rec.Message += "\n" + line
+}
+
+// readLine returns the next line, ok is false when there is none for now.
+func (s *source) readLine() (string, bool, error) {
+	for {
+		if s.reader == nil {
+			if len(s.segments) <= 0 {
+				return "", false, nil
+			}
+			err := s.open(s.segments[0])
+			if err != nil {
+				return "", false, err
+			}
+		}
+
+		data, err := s.reader.ReadString('\n')
+		s.offset += int64(len(data))
+		if err == nil {
+			line := s.partial + strings.TrimSuffix(strings.TrimSuffix(data, "\n"), "\r")
+			s.partial = ""
+			return line, true, nil
+		}
+		if err != io.EOF {
+			return "", false, ee.New(err, "read path:%v", s.segments[0])
+		}
+		s.partial += data
+
+		live := len(s.segments) == 1 && s.follow
+		if live {
+			reopen, err := s.rotated()
+			if err != nil {
+				return "", false, err
+			}
+			if !reopen {
+				return "", false, nil
+			}
+		}
+		if len(s.partial) > 0 {
+			line := s.partial
+			s.partial = ""
+			if !live {
+				s.closeSegment()
+			}
+			return line, true, nil
+		}
+		s.closeSegment()
+	}
+}
+
+// rotated reports whether the followed file was replaced, it rewinds a
+// truncated file.
+func (s *source) rotated() (bool, error) {
+	stat, err := os.Stat(s.path)
+	if err != nil {
+		// moved away and not recreated yet
+		return false, nil
+	}
+	if !os.SameFile(stat, s.stat) {
+		return true, nil
+	}
+	if stat.Size() < s.offset {
+		_, err = s.file.Seek(0, io.SeekStart)
+		if err != nil {
+			return false, ee.New(err, "seek path:%v", s.path)
+		}
+		s.offset = 0
+		s.partial = ""
+		s.reader.Reset(s.file)
+	}
+	return false, nil
+}
+
+func (s *source) open(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return ee.New(err, "os.Open path:%v", path)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return ee.New(err, "file.Stat path:%v", path)
+	}
+	s.file = file
+	s.stat = stat
+	s.offset = 0
+	if strings.HasSuffix(path, ".gz") {
+		s.gz, err = gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return ee.New(err, "gzip.NewReader path:%v", path)
+		}
+		s.reader = bufio.NewReaderSize(s.gz, 64*1024)
+		return nil
+	}
+	s.reader = bufio.NewReaderSize(file, 64*1024)
+	return nil
+}
+
+// closeSegment closes the current segment, the last one is reopened by the
+// next read when following.
+func (s *source) closeSegment() {
+	s.close()
+	if len(s.segments) > 1 || !s.follow {
+		s.segments = s.segments[1:]
+	}
+}
+
+func (s *source) close() {
+	if s.gz != nil {
+		s.gz.Close()
+		s.gz = nil
+	}
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	s.reader = nil
+}
@@ -1,56 +1,233 @@
 package ee
 
 import (
-	"errors"
 	"fmt"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync/atomic"
 )
 
 // / END_OF_LINE
-var endOfLine = "<<EOL>>"
+const defaultEndOfLine = "<<EOL>>"
+
+var endOfLine atomic.Pointer[string]
+
+// SetEndOfLine changes the marker Error puts between the frames of a chain,
+// e.g. "\n" to keep them multi-line. It is safe while errors are rendered.
+func SetEndOfLine(eol string) {
+	endOfLine.Store(&eol)
+}
+
+func getEndOfLine() string {
+	eol := endOfLine.Load()
+	if eol == nil {
+		return defaultEndOfLine
+	}
+	return *eol
+}
+
+// Error is one frame of an error chain: where ee.New was called, its message
+// and the wrapped cause. errors.Is/As/Unwrap see through it.
+type Error struct {
+	// File is the full path, Func the fully qualified function name
+	File  string
+	Line  int
+	Func  string
+	Msg   string
+	Cause error
+	// Attrs and ErrCode add context to this frame, see With and Code
+	Attrs   []Attr
+	ErrCode string
+	// Stack holds the program counters captured by WithStack or the global
+	// stack capture mode, see StackTrace
+	Stack []uintptr
+}
+
+// Attr is a key/value attribute of an error frame.
+type Attr struct {
+	Key   string
+	Value any
+}
 
 func New(err error, format string, a ...any) error {
-	return DoNew(err, 2, format, a...)
+	return DoNewE(err, 2, format, a...)
 }
 
 func DoNew(err error, skip int, format string, a ...any) error {
-	err_str := ""
+	return DoNewE(err, skip+1, format, a...)
+}
+
+// NewE is New returning the frame itself, to add attributes before it is
+// returned as an error:
+//
+//	return ee.NewE(err, "query failed").With("table", t).Code("DB_TIMEOUT")
+func NewE(err error, format string, a ...any) *Error {
+	return DoNewE(err, 2, format, a...)
+}
+
+func DoNewE(err error, skip int, format string, a ...any) *Error {
+	e := &Error{Cause: err}
 	if len(a) <= 0 {
-		err_str = fmt.Sprintf("%s", format)
+		e.Msg = format
 	} else {
-		err_str = fmt.Sprintf(format, a...)
+		e.Msg = fmt.Sprintf(format, a...)
 	}
 
-	var funcName string
-	var pc uintptr
-	var file string
-	var line int
-	var ok bool
-	pc, file, line, ok = runtime.Caller(skip)
+	pc, file, line, ok := runtime.Caller(skip)
 	if !ok {
-		file = "???"
-		line = 0
-		funcName = "???"
+		e.File = "???"
+		e.Func = "???"
 	} else {
-		file = TrimPathN(file, 3)
-		funcName = runtime.FuncForPC(pc).Name()
+		e.File = file
+		e.Line = line
+		e.Func = runtime.FuncForPC(pc).Name()
+	}
+
+	if stackCapture.Load() && !hasError(err) {
+		e.Stack = callers(skip)
+	}
+	return e
+}
+
+// Source renders the location as file:line@func with the last three path
+// segments and the short function name.
+func (e *Error) Source() string {
+	funcName := e.Func
+	if funcName != "???" {
 		funcName = GetLastStrPart(funcName, ".")
 	}
-	addrStr := fmt.Sprintf("%s:%d@%s", file, line, funcName)
+	return TrimPathN(e.File, 3) + ":" + strconv.Itoa(e.Line) + "@" + funcName
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("[%s emsg(%s)]%s%v", e.Source(), e.Msg, getEndOfLine(), e.Cause)
+	}
+	return fmt.Sprintf("[%s emsg(%s)]", e.Source(), e.Msg)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// With adds a key/value attribute to the frame, it returns e for chaining,
+// see NewE.
+func (e *Error) With(key string, value any) *Error {
+	e.Attrs = append(e.Attrs, Attr{Key: key, Value: value})
+	return e
+}
+
+// Code sets the error code or category of the frame.
+func (e *Error) Code(code string) *Error {
+	e.ErrCode = code
+	return e
+}
+
+// With returns err with a key/value attribute added. An ee error is copied
+// rather than changed, it may be shared, any other error is wrapped in a frame
+// at the caller. It returns nil for a nil err.
+func With(err error, key string, value any) error {
+	e := frameOf(err)
+	if e == nil {
+		return nil
+	}
+	e.Attrs = append(e.Attrs[:len(e.Attrs):len(e.Attrs)], Attr{Key: key, Value: value})
+	return e
+}
+
+// WithCode returns err with its error code set, like With.
+func WithCode(err error, code string) error {
+	e := frameOf(err)
+	if e == nil {
+		return nil
+	}
+	e.ErrCode = code
+	return e
+}
+
+// frameOf returns a copy of err when it is an ee error, else a frame wrapping
+// it at the caller of With or WithCode.
+func frameOf(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	e, ok := err.(*Error)
+	if !ok {
+		return DoNewE(err, 3, "")
+	}
+	frame := *e
+	return &frame
+}
 
-	if err != nil {
-		if len(err_str) <= 0 {
-			return errors.New(fmt.Sprintf("[%s emsg()]%s%v", addrStr, endOfLine, err))
+// walk calls fn for err and every error it wraps, depth first and outermost
+// first, the branches of errors.Join included, until fn returns false.
+func walk(err error, fn func(err error) bool) bool {
+	for err != nil {
+		if !fn(err) {
+			return false
+		}
+		switch x := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, inner := range x.Unwrap() {
+				if !walk(inner, fn) {
+					return false
+				}
+			}
+			return true
+		case interface{ Unwrap() error }:
+			err = x.Unwrap()
+		default:
+			return true
 		}
-		return errors.New(fmt.Sprintf("[%s emsg(%s)]%s%v", addrStr, err_str, endOfLine, err))
 	}
-	if len(err_str) <= 0 {
-		return errors.New(fmt.Sprintf("[%s emsg()]", addrStr))
+	return true
+}
+
+// Attrs returns the attributes collected along the chain of err, outermost
+// first, an outer frame wins over an inner one for the same key. The causes
+// of errors.Join are searched in order.
+func Attrs(err error) []Attr {
+	var attrs []Attr
+	walk(err, func(err error) bool {
+		e, ok := err.(*Error)
+		if !ok {
+			return true
+		}
+		for _, attr := range e.Attrs {
+			if !hasAttr(attrs, attr.Key) {
+				attrs = append(attrs, attr)
+			}
+		}
+		return true
+	})
+	return attrs
+}
+
+func hasAttr(attrs []Attr, key string) bool {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return true
+		}
 	}
-	return errors.New(fmt.Sprintf("[%s emsg(%s)]", addrStr, err_str))
+	return false
 }
+
+// CodeOf returns the outermost error code along the chain of err, "" if none.
+func CodeOf(err error) string {
+	code := ""
+	walk(err, func(err error) bool {
+		e, ok := err.(*Error)
+		if ok && len(e.ErrCode) > 0 {
+			code = e.ErrCode
+			return false
+		}
+		return true
+	})
+	return code
+}
+
 func TrimPathN(file string, keep int) string {
 	slashPath := filepath.ToSlash(file) // 转为统一斜杠
 	parts := strings.Split(slashPath, "/")
@@ -67,3 +244,74 @@ func GetLastStrPart(s string, substr string) string {
 	}
 	return s[lastDot+len(substr):]
 }
+
+// Frame is one rendered link of an error chain, Source is empty for the root
+// cause that is not an ee error.
+type Frame struct {
+	Source  string
+	Message string
+}
+
+// Frames returns the chain of err outermost first. Frames with an empty
+// emsg() only add a location and are collapsed, unless nothing else is left.
+func Frames(err error) []Frame {
+	frames := make([]Frame, 0, 4)
+	var last *Error
+	for err != nil {
+		e, ok := err.(*Error)
+		if !ok {
+			frames = append(frames, Frame{Message: err.Error()})
+			return frames
+		}
+		if len(e.Msg) > 0 {
+			frames = append(frames, Frame{Source: e.Source(), Message: e.Msg})
+		}
+		last = e
+		err = e.Cause
+	}
+	if len(frames) == 0 && last != nil {
+		frames = append(frames, Frame{Source: last.Source(), Message: last.Msg})
+	}
+	return frames
+}
+
+// Format renders the chain of err one frame per line, the frames after the
+// first indented by a tab:
+//
+//	[a.go:32@err3 emsg(4444)]
+//		[a.go:22@err1 emsg(2222)]
+//		1111
+func Format(err error) string {
+	return string(AppendFormat(nil, err, "\t"))
+}
+
+// AppendFormat appends the chain of err like Format with indent before every
+// line but the first.
+func AppendFormat(dst []byte, err error, indent string) []byte {
+	for i, frame := range Frames(err) {
+		if i > 0 {
+			dst = append(dst, '\n')
+			dst = append(dst, indent...)
+		}
+		dst = frame.AppendText(dst, indent)
+	}
+	return dst
+}
+
+// AppendText appends the frame as [source emsg(message)], the root cause as
+// its message, continuation lines of the message are indented.
+func (frame Frame) AppendText(dst []byte, indent string) []byte {
+	message := frame.Message
+	if len(indent) > 0 {
+		message = strings.ReplaceAll(message, "\n", "\n"+indent)
+	}
+	if len(frame.Source) == 0 {
+		return append(dst, message...)
+	}
+	dst = append(dst, '[')
+	dst = append(dst, frame.Source...)
+	dst = append(dst, " emsg("...)
+	dst = append(dst, message...)
+	dst = append(dst, ")]"...)
+	return dst
+}
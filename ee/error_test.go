@@ -0,0 +1,182 @@
+package ee
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNewReturnsError(t *testing.T) {
+	err := New(nil, "first")
+	err = errors.New("reassigned")
+	if err == nil {
+		t.Fatal("err nil")
+	}
+
+	var nilErr *Error
+	if DoNew(nil, 1, "x") == error(nilErr) {
+		t.Fatal("DoNew returned a typed nil")
+	}
+}
+
+func TestWithCopies(t *testing.T) {
+	sentinel := New(nil, "not found")
+	err := With(sentinel, "id", 7)
+	err = WithCode(err, "E_NOT_FOUND")
+
+	if attrs := Attrs(sentinel); len(attrs) != 0 {
+		t.Fatalf("sentinel changed, attrs:%v", attrs)
+	}
+	if CodeOf(sentinel) != "" {
+		t.Fatalf("sentinel changed, code:%v", CodeOf(sentinel))
+	}
+	attrs := Attrs(err)
+	if len(attrs) != 1 || attrs[0].Key != "id" || attrs[0].Value != 7 {
+		t.Fatalf("attrs:%v", attrs)
+	}
+	if CodeOf(err) != "E_NOT_FOUND" {
+		t.Fatalf("code:%v", CodeOf(err))
+	}
+	if With(nil, "k", "v") != nil || WithCode(nil, "c") != nil {
+		t.Fatal("With(nil) not nil")
+	}
+}
+
+func TestWithWrapsPlainError(t *testing.T) {
+	cause := errors.New("eof")
+	err := With(cause, "file", "a.txt")
+	if !errors.Is(err, cause) {
+		t.Fatal("cause lost")
+	}
+	var e *Error
+	if !errors.As(err, &e) || !strings.HasSuffix(e.File, "error_test.go") {
+		t.Fatalf("frame not at the caller:%+v", e)
+	}
+	// the frame has no message, Frames collapses it
+	if frames := Frames(err); len(frames) != 1 || frames[0].Message != "eof" {
+		t.Fatalf("frames:%v", frames)
+	}
+}
+
+func TestAttrsThroughJoin(t *testing.T) {
+	a := NewE(nil, "a").With("a", 1).Code("EA")
+	b := NewE(nil, "b").With("b", 2).With("a", 3)
+	err := New(errors.Join(errors.New("plain"), a, b), "both")
+
+	attrs := Attrs(err)
+	got := map[string]any{}
+	for _, attr := range attrs {
+		got[attr.Key] = attr.Value
+	}
+	if len(attrs) != 2 || got["a"] != 1 || got["b"] != 2 {
+		t.Fatalf("attrs:%v", attrs)
+	}
+	if CodeOf(err) != "EA" {
+		t.Fatalf("code:%v", CodeOf(err))
+	}
+}
+
+func TestSetEndOfLineRace(t *testing.T) {
+	defer SetEndOfLine(defaultEndOfLine)
+	err := New(errors.New("cause"), "outer")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = err.Error()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		SetEndOfLine("\n")
+	}
+	<-done
+	if !strings.Contains(err.Error(), "]\ncause") {
+		t.Fatalf("err:%v", err)
+	}
+}
+
+type codeError struct{ code int }
+
+func (e *codeError) Error() string { return "code " + strconv.Itoa(e.code) }
+
+func TestUnwrapIsAs(t *testing.T) {
+	_, err := os.Open(filepath.Join(t.TempDir(), "missing"))
+	wrapped := New(New(err, "open config"), "load")
+
+	if !errors.Is(wrapped, os.ErrNotExist) {
+		t.Errorf("errors.Is(ErrNotExist) false for %v", wrapped)
+	}
+	var pathErr *os.PathError
+	if !errors.As(wrapped, &pathErr) {
+		t.Errorf("errors.As(*os.PathError) false")
+	}
+	var e *Error
+	if !errors.As(wrapped, &e) || e.Msg != "load" || errors.Unwrap(e).(*Error).Msg != "open config" {
+		t.Errorf("errors.As(*Error):%+v", e)
+	}
+
+	joined := New(errors.Join(errors.New("plain"), &codeError{code: 7}), "both")
+	var ce *codeError
+	if !errors.As(joined, &ce) || ce.code != 7 {
+		t.Errorf("errors.As through errors.Join")
+	}
+}
+
+func TestErrorString(t *testing.T) {
+	_, file, line, _ := runtime.Caller(0)
+	inner := New(errors.New("eof"), "read:%v", 3)
+	outer := New(inner, "")
+	source := TrimPathN(file, 3) + ":" + strconv.Itoa(line+1) + "@TestErrorString"
+	if want := "[" + source + " emsg(read:3)]<<EOL>>eof"; inner.Error() != want {
+		t.Errorf("Error() = %q, want %q", inner.Error(), want)
+	}
+	if !strings.HasSuffix(outer.Error(), " emsg()]<<EOL>>"+inner.Error()) {
+		t.Errorf("Error() = %q", outer.Error())
+	}
+	if got := New(nil, "alone").Error(); !strings.HasSuffix(got, " emsg(alone)]") {
+		t.Errorf("Error() without cause = %q", got)
+	}
+
+	e := inner.(*Error)
+	if e.File != file || e.Line != line+1 || e.Func != "github.com/yefy/log4go/ee.TestErrorString" {
+		t.Errorf("location %v:%v %v", e.File, e.Line, e.Func)
+	}
+}
+
+func TestFrames(t *testing.T) {
+	root := errors.New("eof")
+	inner := NewE(root, "read:%v", 3)
+	middle := NewE(inner, "")
+	outer := NewE(middle, "load\nconfig")
+
+	frames := Frames(outer)
+	if len(frames) != 3 {
+		t.Fatalf("frames:%+v", frames)
+	}
+	if frames[0] != (Frame{Source: outer.Source(), Message: "load\nconfig"}) ||
+		frames[1] != (Frame{Source: inner.Source(), Message: "read:3"}) ||
+		frames[2] != (Frame{Message: "eof"}) {
+		t.Errorf("frames:%+v", frames)
+	}
+
+	want := "[" + outer.Source() + " emsg(load\n\tconfig)]\n\t[" + inner.Source() + " emsg(read:3)]\n\teof"
+	if got := Format(outer); got != want {
+		t.Errorf("Format:\n%v\nwant:\n%v", got, want)
+	}
+
+	// a chain of empty messages keeps its last location
+	empty := NewE(nil, "")
+	if frames := Frames(New(empty, "")); len(frames) != 1 || frames[0].Source != empty.Source() {
+		t.Errorf("empty chain:%+v", frames)
+	}
+	if frames := Frames(root); len(frames) != 1 || frames[0] != (Frame{Message: "eof"}) {
+		t.Errorf("plain error:%+v", frames)
+	}
+	if frames := Frames(nil); len(frames) != 0 {
+		t.Errorf("nil:%+v", frames)
+	}
+}
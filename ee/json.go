@@ -0,0 +1,85 @@
+package ee
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type jsonFrame struct {
+	Source  string         `json:"source"`
+	File    string         `json:"file"`
+	Line    int            `json:"line"`
+	Func    string         `json:"func"`
+	Message string         `json:"message"`
+	Code    string         `json:"code,omitempty"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+}
+
+type jsonError struct {
+	Error     string         `json:"error"`
+	Code      string         `json:"code,omitempty"`
+	Attrs     map[string]any `json:"attrs,omitempty"`
+	Frames    []jsonFrame    `json:"frames"`
+	Cause     string         `json:"cause,omitempty"`
+	CauseType string         `json:"cause_type,omitempty"`
+	Stack     []StackFrame   `json:"stack,omitempty"`
+}
+
+// MarshalJSON renders the chain as a structured object: every ee frame with
+// its full location, message, code and attributes outermost first, the merged
+// code and attributes, the root cause with its type and the captured stack.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	out := jsonError{
+		Error: e.Error(),
+		Code:  CodeOf(e),
+		Attrs: attrsMap(Attrs(e)),
+		Stack: StackOf(e),
+	}
+
+	var err error = e
+	for ; err != nil; err = errors.Unwrap(err) {
+		frame, ok := err.(*Error)
+		if !ok {
+			out.Cause = err.Error()
+			out.CauseType = fmt.Sprintf("%T", err)
+			break
+		}
+		out.Frames = append(out.Frames, jsonFrame{
+			Source:  frame.Source(),
+			File:    frame.File,
+			Line:    frame.Line,
+			Func:    frame.Func,
+			Message: frame.Msg,
+			Code:    frame.ErrCode,
+			Attrs:   attrsMap(frame.Attrs),
+		})
+	}
+	return json.Marshal(out)
+}
+
+func attrsMap(attrs []Attr) map[string]any {
+	if len(attrs) == 0 {
+		return nil
+	}
+	m := make(map[string]any, len(attrs))
+	for _, attr := range attrs {
+		if _, ok := m[attr.Key]; !ok {
+			m[attr.Key] = jsonValue(attr.Value)
+		}
+	}
+	return m
+}
+
+// jsonValue keeps values json can encode and renders the others as text,
+// errors as their message.
+func jsonValue(value any) any {
+	if err, ok := value.(error); ok {
+		return err.Error()
+	}
+	_, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return value
+}
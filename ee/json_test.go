@@ -0,0 +1,73 @@
+package ee
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+type jsonTestError struct {
+	Error     string           `json:"error"`
+	Code      string           `json:"code"`
+	Attrs     map[string]any   `json:"attrs"`
+	Frames    []map[string]any `json:"frames"`
+	Cause     string           `json:"cause"`
+	CauseType string           `json:"cause_type"`
+	Stack     []StackFrame     `json:"stack"`
+}
+
+func TestMarshalJSON(t *testing.T) {
+	inner := NewE(os.ErrNotExist, "open").With("path", "/tmp/x").With("err", errors.New("busy")).Code("IO")
+	outer := NewE(inner, "load").With("path", "/tmp/y").With("fn", func() {})
+
+	data, err := json.Marshal(outer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded jsonTestError
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("%v in %s", err, data)
+	}
+
+	if decoded.Error != outer.Error() || decoded.Code != "IO" {
+		t.Errorf("error:%q code:%q", decoded.Error, decoded.Code)
+	}
+	// the outermost attribute wins, values json can't encode are text
+	if decoded.Attrs["path"] != "/tmp/y" || decoded.Attrs["err"] != "busy" || !strings.HasPrefix(decoded.Attrs["fn"].(string), "0x") {
+		t.Errorf("attrs:%+v", decoded.Attrs)
+	}
+	if decoded.Cause != os.ErrNotExist.Error() || decoded.CauseType != "*errors.errorString" {
+		t.Errorf("cause:%q type:%q", decoded.Cause, decoded.CauseType)
+	}
+	if decoded.Stack != nil {
+		t.Errorf("stack:%+v", decoded.Stack)
+	}
+
+	if len(decoded.Frames) != 2 {
+		t.Fatalf("frames:%+v", decoded.Frames)
+	}
+	first, second := decoded.Frames[0], decoded.Frames[1]
+	if first["source"] != outer.Source() || first["message"] != "load" || first["file"] != outer.File ||
+		first["line"] != float64(outer.Line) || first["func"] != outer.Func || first["code"] != nil {
+		t.Errorf("first frame:%+v", first)
+	}
+	if second["message"] != "open" || second["code"] != "IO" || second["attrs"].(map[string]any)["path"] != "/tmp/x" {
+		t.Errorf("second frame:%+v", second)
+	}
+}
+
+func TestMarshalJSONStack(t *testing.T) {
+	data, err := json.Marshal(NewE(nil, "stack").WithStack())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded jsonTestError
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("%v in %s", err, data)
+	}
+	if len(decoded.Stack) == 0 || !strings.HasSuffix(decoded.Stack[0].Func, ".TestMarshalJSONStack") || decoded.Cause != "" {
+		t.Errorf("decoded:%+v", decoded)
+	}
+}
@@ -0,0 +1,73 @@
+package ee
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+)
+
+var stackCapture atomic.Bool
+
+// SetStackCapture switches the global mode capturing the full stack at the
+// innermost ee error of every chain, the one whose cause is not an ee error.
+func SetStackCapture(enable bool) {
+	stackCapture.Store(enable)
+}
+
+// WithStack captures the stack of the caller into the frame, for a single
+// call without the global mode:
+//
+//	return ee.NewE(err, "read config").WithStack()
+func (e *Error) WithStack() *Error {
+	e.Stack = callers(1)
+	return e
+}
+
+// StackFrame is one resolved frame of a captured stack.
+type StackFrame struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// StackTrace resolves the stack captured in the frame, nil if none.
+func (e *Error) StackTrace() []StackFrame {
+	if len(e.Stack) == 0 {
+		return nil
+	}
+	stack := make([]StackFrame, 0, len(e.Stack))
+	frames := runtime.CallersFrames(e.Stack)
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, StackFrame{Func: frame.Function, File: frame.File, Line: frame.Line})
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// StackOf returns the innermost stack captured along the chain of err.
+func StackOf(err error) []StackFrame {
+	var stack []StackFrame
+	walk(err, func(err error) bool {
+		e, ok := err.(*Error)
+		if ok && len(e.Stack) > 0 {
+			stack = e.StackTrace()
+		}
+		return true
+	})
+	return stack
+}
+
+// callers captures the stack, skip counts like runtime.Caller.
+func callers(skip int) []uintptr {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	return pcs[:n]
+}
+
+func hasError(err error) bool {
+	var e *Error
+	return errors.As(err, &e)
+}
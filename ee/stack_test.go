@@ -0,0 +1,58 @@
+package ee
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func hasFunc(stack []StackFrame, name string) bool {
+	for _, frame := range stack {
+		if strings.HasSuffix(frame.Func, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWithStack(t *testing.T) {
+	e := NewE(nil, "no stack")
+	if e.StackTrace() != nil || StackOf(e) != nil {
+		t.Fatalf("stack captured without the mode")
+	}
+
+	e = NewE(nil, "stack").WithStack()
+	stack := e.StackTrace()
+	if len(stack) == 0 || !strings.HasSuffix(stack[0].Func, ".TestWithStack") || stack[0].Line == 0 {
+		t.Fatalf("stack:%+v", stack)
+	}
+	if got := StackOf(New(e, "outer")); len(got) != len(stack) || got[0] != stack[0] {
+		t.Fatalf("StackOf:%+v", got)
+	}
+}
+
+func captureInner() error {
+	return New(errors.New("eof"), "inner")
+}
+
+func TestStackCapture(t *testing.T) {
+	SetStackCapture(true)
+	defer SetStackCapture(false)
+
+	inner := captureInner()
+	outer := New(inner, "outer")
+	if len(outer.(*Error).Stack) != 0 {
+		t.Fatalf("stack captured at the outer frame")
+	}
+	stack := StackOf(outer)
+	if len(stack) == 0 || !strings.HasSuffix(stack[0].Func, ".captureInner") || !hasFunc(stack, ".TestStackCapture") {
+		t.Fatalf("stack:%+v", stack)
+	}
+
+	// StackOf returns the innermost stack
+	SetStackCapture(false)
+	wrapped := NewE(outer, "wrapped").WithStack()
+	if got := StackOf(wrapped); len(got) == 0 || !strings.HasSuffix(got[0].Func, ".captureInner") {
+		t.Fatalf("innermost stack:%+v", got)
+	}
+}
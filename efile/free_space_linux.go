@@ -0,0 +1,23 @@
+//go:build linux
+// +build linux
+
+package efile
+
+import (
+	"path/filepath"
+	"syscall"
+
+	"github.com/yefy/log4go/ee"
+)
+
+// FreeSpace returns the bytes available to the process and the size of the
+// filesystem holding path.
+func FreeSpace(path string) (uint64, uint64, error) {
+	var stat syscall.Statfs_t
+	dir := filepath.Dir(path)
+	err := syscall.Statfs(dir, &stat)
+	if err != nil {
+		return 0, 0, ee.New(err, "syscall.Statfs dir:%v", dir)
+	}
+	return stat.Bavail * uint64(stat.Bsize), stat.Blocks * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,35 @@
+//go:build windows
+// +build windows
+
+package efile
+
+import (
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"github.com/yefy/log4go/ee"
+)
+
+var procGetDiskFreeSpaceExW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// FreeSpace returns the bytes available to the process and the size of the
+// filesystem holding path.
+func FreeSpace(path string) (uint64, uint64, error) {
+	dir := filepath.Dir(path)
+	p, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, 0, ee.New(err, "syscall.UTF16PtrFromString dir:%v", dir)
+	}
+	var free, total, totalFree uint64
+	r, _, err := procGetDiskFreeSpaceExW.Call(
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&free)),
+		uintptr(unsafe.Pointer(&total)),
+		uintptr(unsafe.Pointer(&totalFree)),
+	)
+	if r == 0 {
+		return 0, 0, ee.New(err, "GetDiskFreeSpaceExW dir:%v", dir)
+	}
+	return free, total, nil
+}
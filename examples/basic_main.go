@@ -70,13 +70,20 @@ func doMain() error {
 		log4.Target("test").Trace("test Target Trace")
 		log4.Target("test").Fine("test Target Fine")
 
-		if log4.GetLevel() >= log4.INFO {
+		if log4.Enabled(log4.INFO) {
 			log4.Critical("root Level Critical")
 			log4.Error("root Level Error")
 			log4.Warn("root Level Warn")
 			log4.Info("root Level Info")
 		}
 
+		log4.DebugFn(func() string {
+			return fmt.Sprintf("root DebugFn %v", time.Now())
+		})
+		log4.Target("main").DebugFunc(func(e *log4.Entry) {
+			e.Printf("main DebugFunc %v", time.Now())
+		})
+
 		log4.Critical("=========================================== end")
 		log4.Target("main").Critical("=========================================== end")
 
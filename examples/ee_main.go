@@ -21,7 +21,7 @@ func err() error {
 
 func err1() error {
 	err := err()
-	return ee.New(err, "2222")
+	return ee.NewE(err, "2222").With("step", 1).Code("E2222")
 }
 
 func err2() error {
@@ -47,7 +47,8 @@ func doMain() error {
 	err = startMain()
 	if err != nil {
 		fmt.Printf("err:%v\n", err)
-		log4.Error("err:%v", err)
+		fmt.Printf("err:\n%v\n", ee.Format(err))
+		log4.ErrorErr(err, "startMain")
 	}
 	return nil
 }
@@ -2,4 +2,4 @@ module github.com/yefy/log4go
 
 go 1.23.9
 
-require gopkg.in/yaml.v3 v3.0.1 // indirect
+require gopkg.in/yaml.v3 v3.0.1
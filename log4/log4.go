@@ -1,12 +1,14 @@
 package log4
 
 import (
+	"errors"
 	"fmt"
 	"github.com/yefy/log4go/ee"
 	"github.com/yefy/log4go/efile"
 	"io/ioutil"
-	"regexp"
+	"os"
 	"runtime"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,13 +16,16 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-var newlineRe = regexp.MustCompile(`\r?\n`)
-
 // / END_OF_LINE
 var endOfLine = "<<EOL>>"
 
 var defaultRootTarget = "root"
 
+// terminateFlushTimeout bounds the flush of Fatal and Panic per appender.
+var terminateFlushTimeout = 5 * time.Second
+
+var osExit = os.Exit
+
 // var defaultDiscardTarget = "discard_root"
 var defaultDiscardTarget = defaultRootTarget
 
@@ -37,8 +42,12 @@ func init() {
 func NewLog4(path string) *Log4 {
 	RecordCountStatAdd(Log4StartCount)
 	log4 := &Log4{path: path, appenderMap: make(map[string]Log4Appender), context: NewWaitGroupContext()}
-	log4.TargetMap.Store(defaultRootTarget, NewLog4Target(defaultRootTarget))
-	log4.TargetMap.Store(defaultDiscardTarget, NewLog4Target(defaultDiscardTarget))
+	rootTarget := NewLog4Target(defaultRootTarget)
+	rootTarget.log4 = log4
+	log4.TargetMap.Store(defaultRootTarget, rootTarget)
+	discardTarget := NewLog4Target(defaultDiscardTarget)
+	discardTarget.log4 = log4
+	log4.TargetMap.Store(defaultDiscardTarget, discardTarget)
 	return log4
 }
 
@@ -61,8 +70,14 @@ func (log4 *Log4) Target(targetName string) *Log4Target {
 	return log4TargetI.(*Log4Target)
 }
 
+// AddAppender registers an appender built outside of the config, e.g. by
+// log4test, call it before Run. The loggers of the config refer to it by name.
+func (log4 *Log4) AddAppender(appender Log4Appender) {
+	log4.appenderMap[appender.Name()] = appender
+}
+
 func (log4 *Log4) Run(log4Config *Log4Config) error {
-	err := log4Config.Check()
+	err := log4Config.check(log4.appenderMap)
 	if err != nil {
 		return ee.New(err, "log4Config.Check")
 	}
@@ -80,11 +95,30 @@ func (log4 *Log4) Run(log4Config *Log4Config) error {
 
 			appender := NewLog4FileAppender(name, &v, file)
 			log4.appenderMap[name] = appender
+		} else if isCompositeKind(v.Kind) {
+			log4.appenderMap[name] = NewLog4CompositeAppender(name, &v)
 		} else {
 			return ee.New(err, "not find kind:%v", v.Kind)
 		}
 	}
 
+	for name, v := range log4Config.Appenders {
+		if v.OnError != OnErrorFallback {
+			continue
+		}
+		context, ok := log4.appenderMap[name].(appenderContext)
+		if ok {
+			context.appenderContext().onError.fallback = log4.appenderMap[v.Fallback]
+		}
+	}
+
+	for _, appender := range log4.appenderMap {
+		composite, ok := appender.(*Log4CompositeAppender)
+		if ok {
+			composite.resolve(log4.appenderMap)
+		}
+	}
+
 	for _, appender := range log4.appenderMap {
 		appender.Run()
 	}
@@ -92,6 +126,7 @@ func (log4 *Log4) Run(log4Config *Log4Config) error {
 	createTargetFunc := func(targetName string, logger *Log4ConfigLogger, rootTarget *Log4Target) (*Log4Target, error) {
 		target := NewLog4Target(targetName)
 		target.Name = targetName
+		target.log4 = log4
 		target.Level = LevelNameToLevelDef(logger.Level)
 		target.Logger = logger
 		target.RootTarget = rootTarget
@@ -102,6 +137,18 @@ func (log4 *Log4) Run(log4Config *Log4Config) error {
 			}
 			target.appenders = append(target.appenders, appender)
 		}
+		target.stacktraceLevel = OFF
+		if len(logger.StacktraceLevel) > 0 {
+			target.stacktraceLevel = LevelNameToLevelDef(logger.StacktraceLevel)
+		}
+		multiline, err := logger.ParseMultiline()
+		if err != nil {
+			log4Warn("multiline falls back to %v, err:%v", MultilineReplace, err)
+			multiline = ParseMultilineDef(MultilineReplace, logger.MultilineMarker)
+		}
+		target.multiline = multiline
+		target.needGoroutineId = target.usesCode('G')
+		target.needCaller = target.usesCode('S') || target.usesCode('s') || target.usesCode('F')
 		return target, nil
 	}
 
@@ -119,6 +166,11 @@ func (log4 *Log4) Run(log4Config *Log4Config) error {
 		log4.TargetMap.Store(name, rootTarget)
 	}
 
+	// a Log4 made without a file or refresh rate is never reloaded
+	if len(log4.path) <= 0 || log4.RefreshRate <= 0 {
+		return nil
+	}
+
 	log4.context.Add(1)
 	go func() {
 		RecordCountStatAdd(ReInitFileStartCount)
@@ -159,6 +211,14 @@ func (log4 *Log4) Flush() {
 	}
 }
 
+// FlushSync writes out everything queued in the appenders and waits for it,
+// each appender gets at most timeout.
+func (log4 *Log4) FlushSync(timeout time.Duration) {
+	for _, appender := range log4.appenderMap {
+		appender.FlushSync(timeout)
+	}
+}
+
 func (log4 *Log4) Close(isWait bool) {
 	RecordCountStatAdd(Log4EndCount)
 	for _, appender := range log4.appenderMap {
@@ -174,8 +234,11 @@ func (log4 *Log4) Close(isWait bool) {
 
 func NewLog4Target(name string) *Log4Target {
 	log4Target := &Log4Target{
-		Name:  name,
-		Level: ERROR,
+		Name:            name,
+		Level:           ERROR,
+		stacktraceLevel: OFF,
+		stats:           getTargetStats(name),
+		multiline:       Log4Multiline{Policy: MultilineReplace, Marker: endOfLine},
 	}
 	return log4Target
 }
@@ -186,6 +249,38 @@ type Log4Target struct {
 	Logger     *Log4ConfigLogger
 	RootTarget *Log4Target
 	appenders  []Log4Appender
+
+	stacktraceLevel Level
+	multiline       Log4Multiline
+	stats           *levelCounters
+	needGoroutineId bool
+	needCaller      bool
+	callerSkip      int
+	// log4 owns the target, nil for targets made outside of a Log4
+	log4 *Log4
+}
+
+// WithCallerSkip returns a copy of the target reporting the caller n frames
+// further up, for wrappers around Log4Target.
+func (log4Target *Log4Target) WithCallerSkip(n int) *Log4Target {
+	target := *log4Target
+	target.callerSkip += n
+	return &target
+}
+
+// usesCode reports whether a pattern of the appenders records of this target
+// reach, the root ones included when additive, contains the format code.
+func (log4Target *Log4Target) usesCode(code byte) bool {
+	appenders := log4Target.appenders
+	if log4Target.Logger != nil && log4Target.Logger.Additive && log4Target.RootTarget != nil {
+		appenders = append(appenders[:len(appenders):len(appenders)], log4Target.RootTarget.appenders...)
+	}
+	for _, appender := range appenders {
+		if appenderUsesCode(appender, code) {
+			return true
+		}
+	}
+	return false
 }
 
 func (log4Target *Log4Target) GetLevel() Level {
@@ -219,6 +314,154 @@ func (log4Target *Log4Target) Fine(format string, args ...interface{}) {
 	log4Target.log(3, FINE, format, args...)
 }
 
+// Panic logs at PANIC, flushes every appender and panics with the message.
+func (log4Target *Log4Target) Panic(format string, args ...interface{}) {
+	log4Target.log(3, PANIC, format, args...)
+	log4Target.flushOwner()
+	panic(sprintfMsg(format, args...))
+}
+
+// Fatal logs at FATAL, flushes every appender and exits with status 1.
+func (log4Target *Log4Target) Fatal(format string, args ...interface{}) {
+	log4Target.log(3, FATAL, format, args...)
+	log4Target.flushOwner()
+	osExit(1)
+}
+
+// ErrorErr logs at ERROR with the chain of err rendered as a frame list under
+// the message, or as an array in json layouts.
+func (log4Target *Log4Target) ErrorErr(err error, format string, args ...interface{}) {
+	log4Target.logErr(3, ERROR, err, format, args...)
+}
+
+// LogErr is ErrorErr at any level.
+func (log4Target *Log4Target) LogErr(level Level, err error, format string, args ...interface{}) {
+	log4Target.logErr(3, level, err, format, args...)
+}
+
+// Log logs at any level, including the ones added by RegisterLevel.
+func (log4Target *Log4Target) Log(level Level, format string, args ...interface{}) {
+	log4Target.log(3, level, format, args...)
+}
+
+// Enabled reports whether a record of level passes the target level, it lets
+// callers skip building expensive arguments.
+func (log4Target *Log4Target) Enabled(level Level) bool {
+	return level >= log4Target.Level && log4Target.Level != OFF
+}
+
+func (log4Target *Log4Target) CriticalFn(fn func() string) {
+	log4Target.logFn(4, CRITICAL, fn)
+}
+
+func (log4Target *Log4Target) ErrorFn(fn func() string) {
+	log4Target.logFn(4, ERROR, fn)
+}
+
+func (log4Target *Log4Target) WarnFn(fn func() string) {
+	log4Target.logFn(4, WARNING, fn)
+}
+
+func (log4Target *Log4Target) InfoFn(fn func() string) {
+	log4Target.logFn(4, INFO, fn)
+}
+
+func (log4Target *Log4Target) DebugFn(fn func() string) {
+	log4Target.logFn(4, DEBUG, fn)
+}
+
+func (log4Target *Log4Target) TraceFn(fn func() string) {
+	log4Target.logFn(4, TRACE, fn)
+}
+
+func (log4Target *Log4Target) FineFn(fn func() string) {
+	log4Target.logFn(4, FINE, fn)
+}
+
+func (log4Target *Log4Target) CriticalFunc(fn func(e *Entry)) {
+	log4Target.logFunc(3, CRITICAL, fn)
+}
+
+func (log4Target *Log4Target) ErrorFunc(fn func(e *Entry)) {
+	log4Target.logFunc(3, ERROR, fn)
+}
+
+func (log4Target *Log4Target) WarnFunc(fn func(e *Entry)) {
+	log4Target.logFunc(3, WARNING, fn)
+}
+
+func (log4Target *Log4Target) InfoFunc(fn func(e *Entry)) {
+	log4Target.logFunc(3, INFO, fn)
+}
+
+func (log4Target *Log4Target) DebugFunc(fn func(e *Entry)) {
+	log4Target.logFunc(3, DEBUG, fn)
+}
+
+func (log4Target *Log4Target) TraceFunc(fn func(e *Entry)) {
+	log4Target.logFunc(3, TRACE, fn)
+}
+
+func (log4Target *Log4Target) FineFunc(fn func(e *Entry)) {
+	log4Target.logFunc(3, FINE, fn)
+}
+
+func (log4Target *Log4Target) rootCriticalFn(fn func() string) {
+	log4Target.logFn(5, CRITICAL, fn)
+}
+
+func (log4Target *Log4Target) rootErrorFn(fn func() string) {
+	log4Target.logFn(5, ERROR, fn)
+}
+
+func (log4Target *Log4Target) rootWarnFn(fn func() string) {
+	log4Target.logFn(5, WARNING, fn)
+}
+
+func (log4Target *Log4Target) rootInfoFn(fn func() string) {
+	log4Target.logFn(5, INFO, fn)
+}
+
+func (log4Target *Log4Target) rootDebugFn(fn func() string) {
+	log4Target.logFn(5, DEBUG, fn)
+}
+
+func (log4Target *Log4Target) rootTraceFn(fn func() string) {
+	log4Target.logFn(5, TRACE, fn)
+}
+
+func (log4Target *Log4Target) rootFineFn(fn func() string) {
+	log4Target.logFn(5, FINE, fn)
+}
+
+func (log4Target *Log4Target) rootCriticalFunc(fn func(e *Entry)) {
+	log4Target.logFunc(4, CRITICAL, fn)
+}
+
+func (log4Target *Log4Target) rootErrorFunc(fn func(e *Entry)) {
+	log4Target.logFunc(4, ERROR, fn)
+}
+
+func (log4Target *Log4Target) rootWarnFunc(fn func(e *Entry)) {
+	log4Target.logFunc(4, WARNING, fn)
+}
+
+func (log4Target *Log4Target) rootInfoFunc(fn func(e *Entry)) {
+	log4Target.logFunc(4, INFO, fn)
+}
+
+func (log4Target *Log4Target) rootDebugFunc(fn func(e *Entry)) {
+	log4Target.logFunc(4, DEBUG, fn)
+}
+
+func (log4Target *Log4Target) rootTraceFunc(fn func(e *Entry)) {
+	log4Target.logFunc(4, TRACE, fn)
+}
+
+func (log4Target *Log4Target) rootFineFunc(fn func(e *Entry)) {
+	log4Target.logFunc(4, FINE, fn)
+}
+
 func (log4Target *Log4Target) rootCritical(format string, args ...interface{}) {
 	log4Target.log(4, CRITICAL, format, args...)
 }
@@ -246,12 +489,65 @@ func (log4Target *Log4Target) rootFine(format string, args ...interface{}) {
 	log4Target.log(4, FINE, format, args...)
 }
 
+func (log4Target *Log4Target) rootPanic(format string, args ...interface{}) {
+	log4Target.log(4, PANIC, format, args...)
+	log4Target.flushOwner()
+	panic(sprintfMsg(format, args...))
+}
+
+func (log4Target *Log4Target) rootFatal(format string, args ...interface{}) {
+	log4Target.log(4, FATAL, format, args...)
+	log4Target.flushOwner()
+	osExit(1)
+}
+
+// flushOwner synchronously flushes the Log4 of the target, the current global
+// one for targets made outside of a Log4.
+func (log4Target *Log4Target) flushOwner() {
+	log4 := log4Target.log4
+	if log4 == nil {
+		log4 = GLog4.Load()
+	}
+	log4.FlushSync(terminateFlushTimeout)
+}
+
+func (log4Target *Log4Target) rootErrorErr(err error, format string, args ...interface{}) {
+	log4Target.logErr(4, ERROR, err, format, args...)
+}
+
+func (log4Target *Log4Target) rootLogErr(level Level, err error, format string, args ...interface{}) {
+	log4Target.logErr(4, level, err, format, args...)
+}
+
+func (log4Target *Log4Target) rootLog(level Level, format string, args ...interface{}) {
+	log4Target.log(4, level, format, args...)
+}
+
 func (log4Target *Log4Target) log(skip int, level Level, format string, args ...interface{}) {
-	if level < log4Target.Level {
+	if !log4Target.Enabled(level) {
 		return
 	}
 
 	rec := log4Target.GetRecord(skip, level, format, args...)
+	log4Target.emit(rec)
+}
+
+func (log4Target *Log4Target) logErr(skip int, level Level, err error, format string, args ...interface{}) {
+	if !log4Target.Enabled(level) {
+		return
+	}
+
+	rec := log4Target.GetRecord(skip, level, format, args...)
+	if err != nil {
+		rec.ErrFrames = ee.Frames(err)
+		rec.Fields = mergeFields(errFields(err), rec.Fields)
+	}
+	log4Target.emit(rec)
+}
+
+// emit writes rec to the appenders of the target and of the root target when
+// additive, then releases it.
+func (log4Target *Log4Target) emit(rec *Log4Record) {
 	defer rec.Put()
 
 	log4Target.WriteRecord(rec)
@@ -261,48 +557,157 @@ func (log4Target *Log4Target) log(skip int, level Level, format string, args ...
 	}
 }
 
-func (log4Target *Log4Target) WriteRecord(rec *Log4Record) {
-	for _, appender := range log4Target.appenders {
-		appender.LogRecord(rec.Clone())
+// logFn builds the message with fn only when level is enabled.
+func (log4Target *Log4Target) logFn(skip int, level Level, fn func() string) {
+	if !log4Target.Enabled(level) {
+		return
 	}
+	log4Target.log(skip, level, fn())
 }
 
-func (log4Target *Log4Target) GetRecord(skip int, level Level, format string, args ...interface{}) *Log4Record {
-	// Determine caller func
-	var funcName string
-	var pc uintptr
-	var file string
-	var line int
-	var ok bool
-	pc, file, line, ok = runtime.Caller(skip)
-	if !ok {
-		file = "???"
-		line = 0
-		funcName = "???"
-	} else {
-		file = ee.TrimPathN(file, 3)
-		funcName = runtime.FuncForPC(pc).Name()
-		funcName = ee.GetLastStrPart(funcName, ".")
+// logFunc lets fn fill an Entry only when level is enabled.
+func (log4Target *Log4Target) logFunc(skip int, level Level, fn func(e *Entry)) {
+	if !log4Target.Enabled(level) {
+		return
+	}
+	e := Entry{}
+	fn(&e)
+	rec := log4Target.GetRecord(skip, level, e.format, e.args...)
+	rec.Fields = mergeFields(e.fields, rec.Fields)
+	log4Target.emit(rec)
+}
+
+// errFields returns the error code and the attributes of the chain of err as
+// record fields.
+func errFields(err error) []ee.Attr {
+	attrs := ee.Attrs(err)
+	code := ee.CodeOf(err)
+	if len(code) == 0 {
+		return attrs
 	}
-	src := fmt.Sprintf("%s:%d@%s", file, line, funcName)
+	return append([]ee.Attr{{Key: "error_code", Value: code}}, attrs...)
+}
+
+// argFields returns the fields of the ee errors among args, so that
+// Error("x:%v", err) keeps the attributes of err like ErrorErr does.
+func argFields(args []interface{}) []ee.Attr {
+	var fields []ee.Attr
+	for _, arg := range args {
+		err, ok := arg.(error)
+		if !ok {
+			continue
+		}
+		var e *ee.Error
+		if errors.As(err, &e) {
+			fields = mergeFields(fields, errFields(err))
+		}
+	}
+	return fields
+}
+
+// mergeFields appends the fields of more whose key is not in fields yet.
+func mergeFields(fields []ee.Attr, more []ee.Attr) []ee.Attr {
+	for _, field := range more {
+		found := false
+		for _, have := range fields {
+			if have.Key == field.Key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Entry is filled by the callbacks of the XxxFunc methods.
+type Entry struct {
+	format string
+	args   []interface{}
+	fields []ee.Attr
+}
+
+// With adds a key/value field to the record.
+func (e *Entry) With(key string, value interface{}) *Entry {
+	e.fields = append(e.fields, ee.Attr{Key: key, Value: value})
+	return e
+}
+
+// Err adds the error code and attributes of the chain of err as fields.
+func (e *Entry) Err(err error) *Entry {
+	e.fields = append(e.fields, errFields(err)...)
+	return e
+}
+
+func (e *Entry) Printf(format string, args ...interface{}) {
+	e.format = format
+	e.args = args
+}
 
-	msg := format
+func (e *Entry) Print(msg string) {
+	e.format = msg
+	e.args = nil
+}
+
+func sprintfMsg(format string, args ...interface{}) string {
 	if len(args) > 0 {
-		msg = fmt.Sprintf(format, args...)
+		return fmt.Sprintf(format, args...)
 	}
+	return format
+}
 
-	if !log4Target.Logger.Multiline {
-		msg = newlineRe.ReplaceAllString(msg, endOfLine)
+func (log4Target *Log4Target) WriteRecord(rec *Log4Record) {
+	for _, appender := range log4Target.appenders {
+		appender.LogRecord(rec.Clone())
 	}
+}
+
+func (log4Target *Log4Target) GetRecord(skip int, level Level, format string, args ...interface{}) *Log4Record {
+	msg := sprintfMsg(format, args...)
 
 	// Make the log record
 	rec := NewLog4Record()
 	rec.Target = log4Target.Name
 	rec.Level = LevelToLevelFileName(level)
+	log4Target.stats.add(rec.Level)
 	rec.Created = time.Now()
 	rec.CreatedUtc = time.Now().UTC()
-	rec.Source = src
 	rec.Message = msg
+	rec.Multiline = log4Target.multiline
+	rec.Seq = recordSeq.Add(1)
+
+	// Determine caller func, only when a layout shows it
+	rec.Source = ""
+	rec.File = ""
+	rec.Line = 0
+	rec.Func = ""
+	if log4Target.needCaller {
+		pc, file, line, ok := runtime.Caller(skip + log4Target.callerSkip)
+		if !ok {
+			rec.File = "???"
+			rec.Func = "???"
+		} else {
+			rec.File = file
+			rec.Line = line
+			rec.Func = runtime.FuncForPC(pc).Name()
+		}
+		rec.Source = ee.TrimPathN(rec.File, 3) + ":" + strconv.Itoa(rec.Line) + "@" + ee.GetLastStrPart(rec.Func, ".")
+	}
+
+	rec.ErrFrames = nil
+	rec.Fields = argFields(args)
+	rec.Raw = nil
+	rec.Stack = ""
+	if level >= log4Target.stacktraceLevel && log4Target.stacktraceLevel != OFF {
+		rec.Stack = CallerStack(skip + log4Target.callerSkip)
+	}
+
+	rec.GoroutineId = 0
+	if log4Target.needGoroutineId {
+		rec.GoroutineId = GoroutineId()
+	}
 
 	return rec
 }
@@ -311,6 +716,12 @@ func GetLevel() Level {
 	return Target(defaultRootTarget).GetLevel()
 }
 
+// Enabled reports whether the root target logs level, it follows InitFile
+// reloads.
+func Enabled(level Level) bool {
+	return Target(defaultRootTarget).Enabled(level)
+}
+
 func Critical(format string, args ...interface{}) {
 	Target(defaultRootTarget).rootCritical(format, args...)
 }
@@ -338,6 +749,82 @@ func Fine(format string, args ...interface{}) {
 	Target(defaultRootTarget).rootFine(format, args...)
 }
 
+func Panic(format string, args ...interface{}) {
+	Target(defaultRootTarget).rootPanic(format, args...)
+}
+
+func Fatal(format string, args ...interface{}) {
+	Target(defaultRootTarget).rootFatal(format, args...)
+}
+
+func ErrorErr(err error, format string, args ...interface{}) {
+	Target(defaultRootTarget).rootErrorErr(err, format, args...)
+}
+
+func LogErr(level Level, err error, format string, args ...interface{}) {
+	Target(defaultRootTarget).rootLogErr(level, err, format, args...)
+}
+
+func Log(level Level, format string, args ...interface{}) {
+	Target(defaultRootTarget).rootLog(level, format, args...)
+}
+
+func CriticalFn(fn func() string) {
+	Target(defaultRootTarget).rootCriticalFn(fn)
+}
+
+func ErrorFn(fn func() string) {
+	Target(defaultRootTarget).rootErrorFn(fn)
+}
+
+func WarnFn(fn func() string) {
+	Target(defaultRootTarget).rootWarnFn(fn)
+}
+
+func InfoFn(fn func() string) {
+	Target(defaultRootTarget).rootInfoFn(fn)
+}
+
+func DebugFn(fn func() string) {
+	Target(defaultRootTarget).rootDebugFn(fn)
+}
+
+func TraceFn(fn func() string) {
+	Target(defaultRootTarget).rootTraceFn(fn)
+}
+
+func FineFn(fn func() string) {
+	Target(defaultRootTarget).rootFineFn(fn)
+}
+
+func CriticalFunc(fn func(e *Entry)) {
+	Target(defaultRootTarget).rootCriticalFunc(fn)
+}
+
+func ErrorFunc(fn func(e *Entry)) {
+	Target(defaultRootTarget).rootErrorFunc(fn)
+}
+
+func WarnFunc(fn func(e *Entry)) {
+	Target(defaultRootTarget).rootWarnFunc(fn)
+}
+
+func InfoFunc(fn func(e *Entry)) {
+	Target(defaultRootTarget).rootInfoFunc(fn)
+}
+
+func DebugFunc(fn func(e *Entry)) {
+	Target(defaultRootTarget).rootDebugFunc(fn)
+}
+
+func TraceFunc(fn func(e *Entry)) {
+	Target(defaultRootTarget).rootTraceFunc(fn)
+}
+
+func FineFunc(fn func(e *Entry)) {
+	Target(defaultRootTarget).rootFineFunc(fn)
+}
+
 func Target(targetName string) *Log4Target {
 	log4 := (*Log4)(GLog4.Load())
 	target := log4.Target(targetName)
@@ -352,6 +839,11 @@ func Flush() {
 	log4.Flush()
 }
 
+func FlushSync(timeout time.Duration) {
+	log4 := (*Log4)(GLog4.Load())
+	log4.FlushSync(timeout)
+}
+
 func Close(isWait bool) {
 	log4 := (*Log4)(GLog4.Load())
 	if log4.IsClose {
@@ -0,0 +1,102 @@
+package log4
+
+import (
+	"bytes"
+	"os"
+)
+
+const (
+	colorReset   = "\x1b[0m"
+	colorGray    = "\x1b[90m"
+	colorCyan    = "\x1b[36m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorRed     = "\x1b[31m"
+	colorBoldRed = "\x1b[1;31m"
+)
+
+var levelColorMap = map[Level]string{
+	FINE:     colorGray,
+	TRACE:    colorGray,
+	DEBUG:    colorCyan,
+	INFO:     colorGreen,
+	WARNING:  colorYellow,
+	ERROR:    colorRed,
+	CRITICAL: colorBoldRed,
+	PANIC:    colorBoldRed,
+	FATAL:    colorBoldRed,
+}
+
+// levelColor returns the color of a %L name, custom levels take the color of
+// the closest built-in level below them.
+func levelColor(levelFileName string) (string, bool) {
+	level, ok := LevelFileNameToLevel(levelFileName)
+	if !ok {
+		return "", false
+	}
+	color, ok := levelColorMap[level]
+	if ok {
+		return color, true
+	}
+	best := Level(0)
+	for builtin, builtinColor := range levelColorMap {
+		if builtin <= level && (!ok || builtin > best) {
+			best = builtin
+			color = builtinColor
+			ok = true
+		}
+	}
+	if !ok {
+		return colorGray, true
+	}
+	return color, true
+}
+
+// IsColorEnabled resolves the console color option, auto colors only when file
+// is a terminal and NO_COLOR (https://no-color.org) is not set.
+func IsColorEnabled(color string, file *os.File) bool {
+	switch color {
+	case ConsoleColorAlways:
+		return true
+	case ConsoleColorNever:
+		return false
+	}
+	if len(os.Getenv("NO_COLOR")) > 0 {
+		return false
+	}
+	return IsTerminal(file)
+}
+
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// ColorizeMsg wraps a formatted record in the color of its level, the trailing
+// newline stays outside the escape sequence.
+func ColorizeMsg(levelFileName string, msg string) string {
+	return string(AppendColorized([]byte(msg), levelFileName))
+}
+
+// AppendColorized is the in place form of ColorizeMsg for a formatted record
+// in buf.
+func AppendColorized(buf []byte, levelFileName string) []byte {
+	color, ok := levelColor(levelFileName)
+	if !ok {
+		return buf
+	}
+	body := len(bytes.TrimSuffix(buf, []byte{'\n'}))
+	tail := len(buf) - body
+	buf = append(buf, color...)
+	buf = append(buf, colorReset...)
+	copy(buf[len(color):], buf[:body])
+	copy(buf, color)
+	copy(buf[len(color)+body:], colorReset)
+	for i := 0; i < tail; i++ {
+		buf[len(buf)-tail+i] = '\n'
+	}
+	return buf
+}
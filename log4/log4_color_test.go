@@ -0,0 +1,95 @@
+package log4
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConsoleStream(t *testing.T) {
+	tests := []struct {
+		stream string
+		file   *os.File
+	}{
+		{"", os.Stdout},
+		{ConsoleStreamStdout, os.Stdout},
+		{ConsoleStreamStderr, os.Stderr},
+	}
+	for _, test := range tests {
+		console := NewLog4ConsoleAppender("test_stream", &Log4ConfigAppender{Kind: KindConsole, Stream: test.stream, Color: ConsoleColorNever})
+		if console.File != test.file {
+			t.Errorf("stream %q writes to %v", test.stream, console.File.Name())
+		}
+	}
+
+	for _, appender := range []Log4ConfigAppender{
+		{Kind: KindConsole, Stream: "stdin"},
+		{Kind: KindConsole, Color: "sometimes"},
+	} {
+		config := Log4Config{
+			Appenders: map[string]Log4ConfigAppender{"console": appender},
+			Root:      Log4ConfigLogger{Level: "info", Appenders: []string{"console"}},
+		}
+		if config.Check() == nil {
+			t.Errorf("Check accepts %+v", appender)
+		}
+	}
+}
+
+func TestIsColorEnabled(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "out.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	t.Setenv("NO_COLOR", "")
+	if !IsColorEnabled(ConsoleColorAlways, file) || IsColorEnabled(ConsoleColorNever, file) {
+		t.Error("always or never ignored")
+	}
+	if IsColorEnabled(ConsoleColorAuto, file) || IsColorEnabled("", file) {
+		t.Error("auto colors a regular file")
+	}
+	if IsTerminal(file) {
+		t.Error("a regular file is a terminal")
+	}
+
+	// NO_COLOR only turns auto off
+	t.Setenv("NO_COLOR", "1")
+	if IsColorEnabled(ConsoleColorAuto, file) || !IsColorEnabled(ConsoleColorAlways, file) {
+		t.Error("NO_COLOR")
+	}
+}
+
+func TestAppendColorized(t *testing.T) {
+	tests := []struct {
+		level string
+		want  string
+	}{
+		{"INFO", colorGreen + "[INFO] done" + colorReset + "\n"},
+		{"ERROR", colorRed + "[ERROR] done" + colorReset + "\n"},
+		{"CRIT", colorBoldRed + "[CRIT] done" + colorReset + "\n"},
+		{"nope", "[nope] done\n"},
+	}
+	for _, test := range tests {
+		got := string(AppendColorized([]byte("["+test.level+"] done\n"), test.level))
+		if got != test.want {
+			t.Errorf("%v: %q, want %q", test.level, got, test.want)
+		}
+	}
+	if got := ColorizeMsg("WARN", "no newline"); got != colorYellow+"no newline"+colorReset {
+		t.Errorf("ColorizeMsg:%q", got)
+	}
+}
+
+func TestConsoleColors(t *testing.T) {
+	console, buf := runConsole(t, "test_console_color", &Log4ConfigAppender{Kind: KindConsole, Pattern: "[%L] %M", Color: ConsoleColorAlways})
+	if !console.Context.IsColor {
+		t.Fatal("color always is off")
+	}
+	rec := newBenchRecord()
+	rec.Level = "WARN"
+	console.LogRecord(rec)
+	want := colorYellow + "[WARN] i:12345" + colorReset + "\n"
+	waitFor(t, "the record", func() bool { return buf.String() == want })
+}
@@ -0,0 +1,312 @@
+package log4
+
+import (
+	"sort"
+	"time"
+
+	"github.com/yefy/log4go/ee"
+)
+
+// Composite kinds route records to the appenders named in their appenders
+// list instead of writing them:
+//
+//	failover - to the first healthy one, an appender is unhealthy from a failed
+//	           write until one succeeds, it gets records again every
+//	           retry_after to probe it. The bytes a child fails to write go to
+//	           the next healthy child unless the child sets its own on_error,
+//	           such a child is used by nothing else. Only the last child may
+//	           be a failover or a tee, the others must report their health.
+//	tee      - to every one, with a pattern the record is formatted once, with
+//	           the multiline policy of the tee, and the children write those
+//	           bytes instead of their own format, without their colors.
+const KindFailover = "failover"
+const KindTee = "tee"
+
+const defaultRetryAfter = 30 * time.Second
+
+func isCompositeKind(kind string) bool {
+	return kind == KindFailover || kind == KindTee
+}
+
+// recordLogger is what failed bytes are forwarded to.
+type recordLogger interface {
+	LogRecord(rec *Log4Record)
+}
+
+type logRecordFunc func(rec *Log4Record)
+
+func (fn logRecordFunc) LogRecord(rec *Log4Record) {
+	fn(rec)
+}
+
+// compositeCheck validates a failover or tee appender, appenders are the
+// names it may use.
+func (appender *Log4ConfigAppender) compositeCheck(name string, appenders map[string]bool) error {
+	if appender.Kind == KindFailover && len(appender.Appenders) < 2 {
+		return ee.New(nil, "failover needs a primary and a secondary appender, appenders:%v", appender.Appenders)
+	}
+	if len(appender.Appenders) <= 0 {
+		return ee.New(nil, "appenders nil")
+	}
+	for _, child := range appender.Appenders {
+		if child == name {
+			return ee.New(nil, "appender:%v uses itself", child)
+		}
+		if !appenders[child] {
+			return ee.New(nil, "not find appender:%v", child)
+		}
+	}
+	if len(appender.RetryAfter) > 0 {
+		_, err := time.ParseDuration(appender.RetryAfter)
+		if err != nil {
+			return ee.New(err, "retry_after:%v", appender.RetryAfter)
+		}
+	}
+	return nil
+}
+
+// checkAppenderCycles rejects appenders reaching themselves through the
+// children of composites or fallbacks, records would go round forever.
+func (log4Config *Log4Config) checkAppenderCycles() error {
+	next := func(name string) []string {
+		appender := log4Config.Appenders[name]
+		children := appender.Appenders
+		if appender.OnError == OnErrorFallback {
+			children = append(children[:len(children):len(children)], appender.Fallback)
+		}
+		return children
+	}
+
+	const visiting, done = 1, 2
+	state := make(map[string]int, len(log4Config.Appenders))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return ee.New(nil, "appender cycle:%v", append(path, name))
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		for _, child := range next(name) {
+			err := visit(child, append(path, name))
+			if err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		return nil
+	}
+	for name := range log4Config.Appenders {
+		err := visit(name, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFailoverChildren rejects a failover child that hands its failed bytes
+// on while something else also writes to it: its buffer mixes both, so the
+// failures of the other writes would go to the failover as well. A composite
+// is only allowed as the last child, it has no health to fail over on.
+func (log4Config *Log4Config) checkFailoverChildren() error {
+	users := make(map[string][]string)
+	use := func(user string, names []string) {
+		for _, name := range names {
+			users[name] = append(users[name], user)
+		}
+	}
+	use("root", log4Config.Root.Appenders)
+	for name, logger := range log4Config.Loggers {
+		use("logger:"+name, logger.Appenders)
+	}
+	for name, appender := range log4Config.Appenders {
+		use("appender:"+name, appender.Appenders)
+		if appender.OnError == OnErrorFallback {
+			use("appender:"+name, []string{appender.Fallback})
+		}
+	}
+
+	for name, appender := range log4Config.Appenders {
+		if appender.Kind != KindFailover {
+			continue
+		}
+		for _, child := range appender.Appenders[:len(appender.Appenders)-1] {
+			childAppender, ok := log4Config.Appenders[child]
+			if ok && isCompositeKind(childAppender.Kind) {
+				return ee.New(nil, "appender:%v of failover:%v is a %v, only the last appender may be one", child, name, childAppender.Kind)
+			}
+			if !ok || len(childAppender.OnError) > 0 || len(users[child]) <= 1 {
+				continue
+			}
+			sort.Strings(users[child])
+			return ee.New(nil, "appender:%v of failover:%v is also used by:%v, set its on_error", child, name, users[child])
+		}
+	}
+	return nil
+}
+
+// Log4CompositeAppender is a failover or tee appender, it has no queue of its
+// own, the children are run, flushed and closed by the Log4.
+type Log4CompositeAppender struct {
+	name       string
+	Appender   *Log4ConfigAppender
+	layout     *Log4Layout
+	multiline  Log4Multiline
+	retryAfter time.Duration
+	children   []Log4Appender
+}
+
+func NewLog4CompositeAppender(name string, Appender *Log4ConfigAppender) *Log4CompositeAppender {
+	composite := &Log4CompositeAppender{
+		name:       name,
+		Appender:   Appender,
+		retryAfter: defaultRetryAfter,
+	}
+	if len(Appender.Pattern) > 0 || Appender.Layout == LayoutJson {
+		composite.layout = NewLog4Layout(Appender.Pattern, Appender.LayoutOptions())
+		composite.multiline = ParseMultilineDef(Appender.Multiline, Appender.MultilineMarker)
+	}
+	if len(Appender.RetryAfter) > 0 {
+		retryAfter, err := time.ParseDuration(Appender.RetryAfter)
+		if err == nil {
+			composite.retryAfter = retryAfter
+		}
+	}
+	return composite
+}
+
+// resolve looks the children up once every appender of the Log4 exists.
+func (log *Log4CompositeAppender) resolve(appenderMap map[string]Log4Appender) {
+	log.children = log.children[:0]
+	for _, name := range log.Appender.Appenders {
+		log.children = append(log.children, appenderMap[name])
+	}
+	if log.Appender.Kind != KindFailover {
+		return
+	}
+	// all but the last child hand their failed bytes on
+	for i, child := range log.children[:len(log.children)-1] {
+		context, ok := child.(appenderContext)
+		if !ok || len(context.appenderContext().Appender.OnError) > 0 {
+			continue
+		}
+		next := i + 1
+		context.appenderContext().onError.policy = OnErrorFallback
+		context.appenderContext().onError.fallback = logRecordFunc(func(rec *Log4Record) {
+			log.logFrom(next, rec)
+		})
+	}
+}
+
+func (log *Log4CompositeAppender) Name() string {
+	return log.name
+}
+
+// Layout is the tee pattern, or the layout of the first child.
+func (log *Log4CompositeAppender) Layout() *Log4Layout {
+	if log.layout != nil || len(log.children) == 0 {
+		return log.layout
+	}
+	return log.children[0].Layout()
+}
+
+// usesCode is used by Log4Target.usesCode instead of the single Layout.
+func (log *Log4CompositeAppender) usesCode(code byte) bool {
+	if log.layout != nil {
+		return log.layout.UsesCode(code)
+	}
+	for _, child := range log.children {
+		if appenderUsesCode(child, code) {
+			return true
+		}
+	}
+	return false
+}
+
+func appenderUsesCode(appender Log4Appender, code byte) bool {
+	user, ok := appender.(interface{ usesCode(code byte) bool })
+	if ok {
+		return user.usesCode(code)
+	}
+	layout := appender.Layout()
+	return layout != nil && layout.UsesCode(code)
+}
+
+func (log *Log4CompositeAppender) LogRecord(rec *Log4Record) {
+	if log.Appender.Kind == KindFailover {
+		log.logFrom(0, rec)
+		return
+	}
+
+	if log.layout != nil && rec.Raw == nil {
+		multiline := rec.Multiline
+		if len(log.multiline.Policy) > 0 {
+			multiline = log.multiline
+		}
+		raw := NewRawRecord(log.layout.appendFormat(nil, rec, nil, log.layout.IsUtc, multiline))
+		raw.Level = rec.Level
+		raw.Created = rec.Created
+		raw.CreatedUtc = rec.CreatedUtc
+		rec.Put()
+		rec = raw
+	}
+	for _, child := range log.children {
+		child.LogRecord(rec.Clone())
+	}
+	rec.Put()
+}
+
+// logFrom hands rec to the first healthy child from start, the last child
+// when none is.
+func (log *Log4CompositeAppender) logFrom(start int, rec *Log4Record) {
+	if start >= len(log.children) {
+		rec.Put()
+		return
+	}
+	for _, child := range log.children[start : len(log.children)-1] {
+		if log.healthy(child) {
+			child.LogRecord(rec)
+			return
+		}
+	}
+	log.children[len(log.children)-1].LogRecord(rec)
+}
+
+// healthy reports whether the child writes, or failed long enough ago to be
+// probed again.
+func (log *Log4CompositeAppender) healthy(child Log4Appender) bool {
+	context, ok := child.(appenderContext)
+	if !ok {
+		return true
+	}
+	failedAt := context.appenderContext().failedAt.Load()
+	return failedAt == 0 || time.Since(time.Unix(0, failedAt)) >= log.retryAfter
+}
+
+func (log *Log4CompositeAppender) Run() {}
+
+func (log *Log4CompositeAppender) Flush() {}
+
+func (log *Log4CompositeAppender) FlushSync(timeout time.Duration) bool {
+	return true
+}
+
+func (log *Log4CompositeAppender) Close(isWait bool) {}
+
+func (log *Log4CompositeAppender) BufferWrite(msg string) error {
+	return nil
+}
+
+func (log *Log4CompositeAppender) BufferFlush() error {
+	return nil
+}
+
+func (log *Log4CompositeAppender) BufferSize() int {
+	return 0
+}
+
+func (log *Log4CompositeAppender) BufferClose() error {
+	return nil
+}
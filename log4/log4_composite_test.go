@@ -0,0 +1,88 @@
+package log4_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yefy/log4go/log4"
+	"github.com/yefy/log4go/log4test"
+)
+
+// logSync logs message at level and waits until every appender wrote it.
+func logSync(rec *log4test.Recorder, level log4.Level, message string) []log4test.Record {
+	rec.Target("").Log(level, message)
+	rec.Log4().FlushSync(time.Second)
+	return rec.Records()
+}
+
+func TestFailoverSwitchAndProbe(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("no /dev/full")
+	}
+	log4.SetStatusHandler(func(log4.StatusEvent) {})
+	defer log4.SetStatusHandler(nil)
+
+	rec := log4test.New(t, log4test.WithConfig(func(config *log4.Log4Config) {
+		config.Appenders["test_full"] = log4.Log4ConfigAppender{Kind: log4.KindFile, Path: "/dev/full", Pattern: "%M"}
+		config.Appenders["net"] = log4.Log4ConfigAppender{Kind: log4.KindFailover, Appenders: []string{"test_full", log4test.AppenderName}, RetryAfter: "100ms"}
+		config.Root.Appenders = []string{"net"}
+	}))
+
+	// the failed bytes of the primary move on to the next child
+	records := logSync(rec, log4.WARNING, "first")
+	if len(records) != 1 || records[0].Text != "first" || records[0].Level != log4.WARNING || records[0].Target != "" {
+		t.Fatalf("forwarded:%+v", records)
+	}
+
+	// the failed primary is skipped
+	records = logSync(rec, log4.INFO, "second")
+	if len(records) != 2 || records[1].Message != "second" || records[1].Target != "root" {
+		t.Fatalf("switched:%+v", records)
+	}
+
+	// and probed again after retry_after
+	time.Sleep(150 * time.Millisecond)
+	records = logSync(rec, log4.INFO, "third")
+	if len(records) != 3 || records[2].Text != "third" || records[2].Target != "" {
+		t.Fatalf("probed:%+v", records)
+	}
+}
+
+func TestTeeFanOut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tee.log")
+	rec := log4test.New(t, log4test.WithLoggers("plain"), log4test.WithConfig(func(config *log4.Log4Config) {
+		config.Appenders["test_tee_file"] = log4.Log4ConfigAppender{Kind: log4.KindFile, Path: path, Pattern: "%M"}
+		config.Appenders["formatted"] = log4.Log4ConfigAppender{Kind: log4.KindTee, Pattern: "[%L] %M", Multiline: log4.MultilineEscape,
+			Appenders: []string{"test_tee_file", log4test.AppenderName}}
+		config.Appenders["plain"] = log4.Log4ConfigAppender{Kind: log4.KindTee, Appenders: []string{"test_tee_file", log4test.AppenderName}}
+		config.Root.Appenders = []string{"formatted"}
+		config.Loggers["plain"] = log4.Log4ConfigLogger{Level: "all", Multiline: true, Appenders: []string{"plain"}}
+	}))
+
+	// formatted once with the pattern and the multiline policy of the tee
+	rec.Target("").Error("a\nb")
+	// every child formats the record itself
+	rec.Target("plain").Info("c\nd")
+	rec.Log4().FlushSync(time.Second)
+
+	records := rec.Records()
+	if len(records) != 2 {
+		t.Fatalf("records:%+v", records)
+	}
+	if records[0].Text != `[ERROR] a\nb` || records[0].Level != log4.ERROR {
+		t.Errorf("tee with a pattern:%+v", records[0])
+	}
+	if records[1].Message != "c\nd" || records[1].Target != "plain" {
+		t.Errorf("tee without a pattern:%+v", records[1])
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "[ERROR] a\\nb\nc\nd\n" {
+		t.Errorf("file:%q", data)
+	}
+}
@@ -1,8 +1,11 @@
 package log4
 
 import (
+	"strconv"
+
 	"github.com/yefy/log4go/ee"
 	"github.com/yefy/log4go/efile"
+	"gopkg.in/yaml.v3"
 )
 
 //go:generate gomodifytags -file log4_config.go -struct Log4Config -add-tags yaml -transform snakecase -w
@@ -14,16 +17,70 @@ type Log4Config struct {
 }
 
 func (log4Config *Log4Config) Check() error {
-	appenders := make([]string, 0, len(log4Config.Appenders))
+	return log4Config.check(nil)
+}
+
+// check also accepts the appenders registered with Log4.AddAppender.
+func (log4Config *Log4Config) check(registered map[string]Log4Appender) error {
+	appenders := make([]string, 0, len(log4Config.Appenders)+len(registered))
+	appenderNames := make(map[string]bool, len(log4Config.Appenders)+len(registered))
+	for appender := range registered {
+		appenders = append(appenders, appender)
+		appenderNames[appender] = true
+	}
+	for appender := range log4Config.Appenders {
+		appenderNames[appender] = true
+	}
 	for appender, v := range log4Config.Appenders {
 		appenders = append(appenders, appender)
-		if v.Kind != KindConsole && v.Kind != KindFile {
-			return ee.New(nil, "not find kind:%v, use:%+v|%+v in appenders:%v|%+v", v.Kind, KindConsole, KindFile, appender, v)
+		if v.Kind != KindConsole && v.Kind != KindFile && !isCompositeKind(v.Kind) {
+			return ee.New(nil, "not find kind:%v, use:%+v|%+v|%+v|%+v in appenders:%v|%+v", v.Kind, KindConsole, KindFile, KindFailover, KindTee, appender, v)
+		}
+
+		if isCompositeKind(v.Kind) {
+			err := v.compositeCheck(appender, appenderNames)
+			if err != nil {
+				return ee.New(err, "in appenders:%v|%+v", appender, v)
+			}
+		}
+
+		err := v.LayoutOptions().Check()
+		if err != nil {
+			return ee.New(err, "in appenders:%v|%+v", appender, v)
+		}
+
+		_, err = ParseMultiline(v.Multiline, v.MultilineMarker)
+		if err != nil {
+			return ee.New(err, "in appenders:%v|%+v", appender, v)
+		}
+
+		err = v.onErrorCheck(appender, appenderNames)
+		if err != nil {
+			return ee.New(err, "in appenders:%v|%+v", appender, v)
+		}
+
+		err = v.spaceCheck()
+		if err != nil {
+			return ee.New(err, "in appenders:%v|%+v", appender, v)
+		}
+
+		err = v.durabilityCheck()
+		if err != nil {
+			return ee.New(err, "in appenders:%v|%+v", appender, v)
+		}
+
+		if v.Kind == KindConsole {
+			if len(v.Stream) > 0 && v.Stream != ConsoleStreamStdout && v.Stream != ConsoleStreamStderr {
+				return ee.New(nil, "not find stream:%v, use:%+v|%+v in appenders:%v|%+v", v.Stream, ConsoleStreamStdout, ConsoleStreamStderr, appender, v)
+			}
+			if len(v.Color) > 0 && v.Color != ConsoleColorAuto && v.Color != ConsoleColorAlways && v.Color != ConsoleColorNever {
+				return ee.New(nil, "not find color:%v, use:%+v|%+v|%+v in appenders:%v|%+v", v.Color, ConsoleColorAuto, ConsoleColorAlways, ConsoleColorNever, appender, v)
+			}
 		}
 
 		if v.Kind == KindFile {
 			if len(v.Path) <= 0 {
-				return ee.New(nil, "open path nil in appenders:%v|%+v", v.Path, appender, v)
+				return ee.New(nil, "open path nil in appenders:%v|%+v", appender, v)
 			}
 
 			err := efile.EnsureLogDirExists(v.Path)
@@ -39,15 +96,37 @@ func (log4Config *Log4Config) Check() error {
 		}
 	}
 
+	err := log4Config.checkAppenderCycles()
+	if err != nil {
+		return err
+	}
+
+	err = log4Config.checkFailoverChildren()
+	if err != nil {
+		return err
+	}
+
 	{
 		_, err := LevelNameToLevel(log4Config.Root.Level)
 		if err != nil {
 			return ee.New(err, "LevelNameToLevel in root:%+v", log4Config.Root)
 		}
 
+		if len(log4Config.Root.StacktraceLevel) > 0 {
+			_, err := LevelNameToLevel(log4Config.Root.StacktraceLevel)
+			if err != nil {
+				return ee.New(err, "LevelNameToLevel stacktrace_level in root:%+v", log4Config.Root)
+			}
+		}
+
+		_, err = log4Config.Root.ParseMultiline()
+		if err != nil {
+			return ee.New(err, "in root:%+v", log4Config.Root)
+		}
+
 		for _, appender := range log4Config.Root.Appenders {
 			_, ok := log4Config.Appenders[appender]
-			if !ok {
+			if !ok && registered[appender] == nil {
 				return ee.New(nil, "not find appender:%v, use:%+v in root:%+v", appender, appenders, log4Config.Root)
 			}
 		}
@@ -66,9 +145,21 @@ func (log4Config *Log4Config) Check() error {
 			return ee.New(err, "LevelNameToLevel in loggers:%v|%+v\"", k, v)
 		}
 
+		if len(v.StacktraceLevel) > 0 {
+			_, err := LevelNameToLevel(v.StacktraceLevel)
+			if err != nil {
+				return ee.New(err, "LevelNameToLevel stacktrace_level in loggers:%v|%+v\"", k, v)
+			}
+		}
+
+		_, err = v.ParseMultiline()
+		if err != nil {
+			return ee.New(err, "in loggers:%v|%+v\"", k, v)
+		}
+
 		for _, appender := range v.Appenders {
 			_, ok := log4Config.Appenders[appender]
-			if !ok {
+			if !ok && registered[appender] == nil {
 				return ee.New(nil, "not find appender:%v, use:%+v in loggers:%v|%+v", appender, appenders, k, v)
 			}
 		}
@@ -79,11 +170,55 @@ func (log4Config *Log4Config) Check() error {
 const KindConsole = "console"
 const KindFile = "file"
 
+const ConsoleStreamStdout = "stdout"
+const ConsoleStreamStderr = "stderr"
+
+const ConsoleColorAuto = "auto"
+const ConsoleColorAlways = "always"
+const ConsoleColorNever = "never"
+
 //go:generate gomodifytags -file log4_config.go -struct Log4ConfigAppender -add-tags yaml -transform snakecase -w
 type Log4ConfigAppender struct {
 	Kind    string `yaml:"kind"`
 	Pattern string `yaml:"pattern"`
 	Path    string `yaml:"path"`
+	Stream  string `yaml:"stream"`
+	Color   string `yaml:"color"`
+	// Layout is pattern (default) or json
+	Layout string `yaml:"layout"`
+	// SourcePath is base, full, module or a number of trailing path segments, 3 by default
+	SourcePath string `yaml:"source_path"`
+	// SourceFunc is short or full
+	SourceFunc string `yaml:"source_func"`
+	// Multiline overrides the policy of the loggers: keep, replace, escape or indent
+	Multiline       string `yaml:"multiline"`
+	MultilineMarker string `yaml:"multiline_marker"`
+	// OnError is retry (default), fallback, callback or drop, see OnErrorRetry
+	OnError      string `yaml:"on_error"`
+	RetryCount   int    `yaml:"retry_count"`
+	RetryBackoff string `yaml:"retry_backoff"`
+	Fallback     string `yaml:"fallback"`
+	ErrorHandler string `yaml:"error_handler"`
+	// Appenders are the children of the failover and tee kinds, RetryAfter
+	// is how often a failed failover child is probed, 30s by default
+	Appenders  []string `yaml:"appenders"`
+	RetryAfter string   `yaml:"retry_after"`
+	// MinFreeSpace is a size such as 1GB or a percent such as 5% of the
+	// filesystem of a file appender, below it LowSpace applies, warn by
+	// default, see LowSpaceWarn. SpaceCheckInterval is 10s by default
+	MinFreeSpace       string `yaml:"min_free_space"`
+	LowSpace           string `yaml:"low_space"`
+	SpaceCheckInterval string `yaml:"space_check_interval"`
+	// Durability flushes and fsyncs a file appender beyond the buffer
+	Durability Log4ConfigDurability `yaml:"durability"`
+}
+
+func (appender *Log4ConfigAppender) LayoutOptions() Log4LayoutOptions {
+	return Log4LayoutOptions{
+		Layout:     appender.Layout,
+		SourcePath: appender.SourcePath,
+		SourceFunc: appender.SourceFunc,
+	}
 }
 
 //go:generate gomodifytags -file log4_config.go -struct Log4ConfigLogger -add-tags yaml -transform snakecase -w
@@ -92,4 +227,64 @@ type Log4ConfigLogger struct {
 	Multiline bool     `yaml:"multiline"`
 	Additive  bool     `yaml:"additive"`
 	Appenders []string `yaml:"appenders"`
+	// StacktraceLevel captures the goroutine stack of records at or above it
+	StacktraceLevel string `yaml:"stacktrace_level"`
+	// MultilineMarker replaces newlines for replace, prefixes continuation
+	// lines for indent
+	MultilineMarker string `yaml:"multiline_marker"`
+	// MultilinePolicy is keep, replace, escape or indent and wins over
+	// Multiline, true is keep and false replace. The yaml multiline field
+	// also takes a policy name.
+	MultilinePolicy string `yaml:"multiline_policy"`
+}
+
+// UnmarshalYAML moves a policy name given as multiline to MultilinePolicy.
+func (logger *Log4ConfigLogger) UnmarshalYAML(value *yaml.Node) error {
+	type plain Log4ConfigLogger
+	policy := ""
+	if value.Kind == yaml.MappingNode {
+		node := *value
+		node.Content = make([]*yaml.Node, 0, len(value.Content))
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			key, val := value.Content[i], value.Content[i+1]
+			if key.Value == "multiline" && val.Kind == yaml.ScalarNode {
+				var multiline bool
+				if val.Decode(&multiline) != nil {
+					policy = val.Value
+					continue
+				}
+			}
+			node.Content = append(node.Content, key, val)
+		}
+		value = &node
+	}
+	err := value.Decode((*plain)(logger))
+	if err != nil {
+		return err
+	}
+	if len(policy) > 0 && len(logger.MultilinePolicy) <= 0 {
+		logger.MultilinePolicy = policy
+	}
+	return nil
+}
+
+// ParseMultiline returns the multiline policy of the logger.
+func (logger *Log4ConfigLogger) ParseMultiline() (Log4Multiline, error) {
+	if len(logger.MultilinePolicy) > 0 {
+		return ParseMultiline(logger.MultilinePolicy, logger.MultilineMarker)
+	}
+	return ParseMultiline(strconv.FormatBool(logger.Multiline), logger.MultilineMarker)
+}
+
+//go:generate gomodifytags -file log4_config.go -struct Log4ConfigDurability -add-tags yaml -transform snakecase -w
+type Log4ConfigDurability struct {
+	// FlushLevel writes the buffer out after records at or above it
+	FlushLevel string `yaml:"flush_level"`
+	// SyncLevel also fsyncs the file after records at or above it
+	SyncLevel string `yaml:"sync_level"`
+	// SyncInterval fsyncs the written records this often
+	SyncInterval string `yaml:"sync_interval"`
+	// Audit makes the loggers wait until their record is written and fsynced,
+	// the records queued meanwhile share the fsync
+	Audit bool `yaml:"audit"`
 }
@@ -0,0 +1,111 @@
+package log4
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestLoggerMultilineYaml(t *testing.T) {
+	tests := []struct {
+		yaml   string
+		policy string
+	}{
+		{"level: info", MultilineReplace},
+		{"multiline: true", MultilineKeep},
+		{"multiline: false", MultilineReplace},
+		{"multiline: indent", MultilineIndent},
+		{"multiline: escape\nlevel: info", MultilineEscape},
+		{"multiline: true\nmultiline_policy: indent", MultilineIndent},
+	}
+	for _, test := range tests {
+		var logger Log4ConfigLogger
+		err := yaml.Unmarshal([]byte(test.yaml), &logger)
+		if err != nil {
+			t.Fatalf("%q: %v", test.yaml, err)
+		}
+		multiline, err := logger.ParseMultiline()
+		if err != nil || multiline.Policy != test.policy {
+			t.Errorf("%q: policy %q, err:%v, want %q", test.yaml, multiline.Policy, err, test.policy)
+		}
+	}
+
+	var config Log4Config
+	err := yaml.Unmarshal([]byte("root:\n  level: info\n  multiline: bogus\n  appenders: []\n"), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Root.Level != "info" || config.Check() == nil {
+		t.Errorf("root:%+v, Check accepts multiline bogus", config.Root)
+	}
+
+	// Go programs keep building the config with a bool
+	logger := Log4ConfigLogger{Level: "info", Multiline: true}
+	multiline, _ := logger.ParseMultiline()
+	if multiline.Policy != MultilineKeep {
+		t.Errorf("Multiline: true is %q", multiline.Policy)
+	}
+}
+
+func TestFailoverChildUsedElsewhere(t *testing.T) {
+	tests := []struct {
+		name    string
+		root    []string
+		onError string
+		tee     []string
+		ok      bool
+	}{
+		{"failover only", []string{"net"}, "", nil, true},
+		{"primary on root", []string{"net", "primary"}, "", nil, false},
+		{"primary in a tee", []string{"net", "both"}, "", []string{"primary", "secondary"}, false},
+		{"primary with on_error", []string{"net", "primary"}, OnErrorDrop, nil, true},
+		{"secondary on root", []string{"net", "secondary"}, "", nil, true},
+	}
+	composites := []struct {
+		name     string
+		children []string
+		ok       bool
+	}{
+		{"tee as primary", []string{"both", "secondary"}, false},
+		{"failover as primary", []string{"inner", "secondary"}, false},
+		{"tee as last", []string{"third", "both"}, true},
+	}
+	for _, test := range composites {
+		config := Log4Config{
+			Appenders: map[string]Log4ConfigAppender{
+				"primary":   {Kind: KindConsole},
+				"secondary": {Kind: KindConsole},
+				"third":     {Kind: KindConsole},
+				"net":       {Kind: KindFailover, Appenders: test.children},
+			},
+			Root: Log4ConfigLogger{Level: "info", Appenders: []string{"net"}},
+		}
+		switch test.children[0] {
+		case "inner":
+			config.Appenders["inner"] = Log4ConfigAppender{Kind: KindFailover, Appenders: []string{"primary", "third"}}
+		default:
+			config.Appenders["both"] = Log4ConfigAppender{Kind: KindTee, Appenders: []string{"primary", "secondary"}}
+		}
+		err := config.Check()
+		if (err == nil) != test.ok {
+			t.Errorf("%v: Check = %v", test.name, err)
+		}
+	}
+	for _, test := range tests {
+		config := Log4Config{
+			Appenders: map[string]Log4ConfigAppender{
+				"primary":   {Kind: KindConsole, OnError: test.onError},
+				"secondary": {Kind: KindConsole},
+				"net":       {Kind: KindFailover, Appenders: []string{"primary", "secondary"}},
+			},
+			Root: Log4ConfigLogger{Level: "info", Appenders: test.root},
+		}
+		if test.tee != nil {
+			config.Appenders["both"] = Log4ConfigAppender{Kind: KindTee, Appenders: test.tee}
+		}
+		err := config.Check()
+		if (err == nil) != test.ok {
+			t.Errorf("%v: Check = %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package log4
+
+import (
+	"time"
+
+	"github.com/yefy/log4go/ee"
+)
+
+func (durability *Log4ConfigDurability) isSet() bool {
+	return len(durability.FlushLevel) > 0 || len(durability.SyncLevel) > 0 || len(durability.SyncInterval) > 0 || durability.Audit
+}
+
+// durabilityCheck validates the durability of an appender, only files have one.
+func (appender *Log4ConfigAppender) durabilityCheck() error {
+	durability := &appender.Durability
+	if !durability.isSet() {
+		return nil
+	}
+	if appender.Kind != KindFile {
+		return ee.New(nil, "durability with kind:%v, use:%v", appender.Kind, KindFile)
+	}
+	if len(durability.FlushLevel) > 0 {
+		_, err := LevelNameToLevel(durability.FlushLevel)
+		if err != nil {
+			return ee.New(err, "LevelNameToLevel flush_level")
+		}
+	}
+	if len(durability.SyncLevel) > 0 {
+		_, err := LevelNameToLevel(durability.SyncLevel)
+		if err != nil {
+			return ee.New(err, "LevelNameToLevel sync_level")
+		}
+	}
+	if len(durability.SyncInterval) > 0 {
+		interval, err := time.ParseDuration(durability.SyncInterval)
+		if err != nil {
+			return ee.New(err, "sync_interval:%v", durability.SyncInterval)
+		}
+		if interval <= 0 {
+			return ee.New(nil, "sync_interval:%v <= 0", durability.SyncInterval)
+		}
+	}
+	return nil
+}
+
+// durabilityPolicy is the resolved durability of a file appender, only its
+// goroutine changes it.
+type durabilityPolicy struct {
+	flushLevel   Level
+	syncLevel    Level
+	syncInterval time.Duration
+	audit        bool
+	// dirty is set by written records until the next fsync
+	dirty    bool
+	lastSync time.Time
+}
+
+// newDurabilityPolicy returns nil when the appender sets no durability.
+func newDurabilityPolicy(appender *Log4ConfigAppender) *durabilityPolicy {
+	durability := &appender.Durability
+	if !durability.isSet() {
+		return nil
+	}
+	policy := &durabilityPolicy{
+		flushLevel: OFF,
+		syncLevel:  OFF,
+		audit:      durability.Audit,
+		lastSync:   time.Now(),
+	}
+	if len(durability.FlushLevel) > 0 {
+		policy.flushLevel = LevelNameToLevelDef(durability.FlushLevel)
+	}
+	if len(durability.SyncLevel) > 0 {
+		policy.syncLevel = LevelNameToLevelDef(durability.SyncLevel)
+	}
+	if len(durability.SyncInterval) > 0 {
+		interval, err := time.ParseDuration(durability.SyncInterval)
+		if err == nil && interval > 0 {
+			policy.syncInterval = interval
+		}
+	}
+	return policy
+}
+
+func (policy *durabilityPolicy) isAudit() bool {
+	return policy != nil && policy.audit
+}
+
+// syncer is implemented by the appenders whose writes can be made durable.
+type syncer interface {
+	// sync writes the buffer out and fsyncs it
+	sync() error
+}
+
+// durableWrite flushes or fsyncs after rec was written, by its level.
+func (context *Log4AppenderContext) durableWrite(log Log4Appender, rec *Log4Record) {
+	policy := context.durability
+	if policy == nil {
+		return
+	}
+	policy.dirty = true
+	level, ok := LevelFileNameToLevel(rec.Level)
+	if !ok {
+		return
+	}
+	if level >= policy.syncLevel {
+		context.durableSync(log)
+	} else if level >= policy.flushLevel {
+		log.BufferFlush()
+	}
+}
+
+// durableTick fsyncs once sync_interval has passed since the last fsync.
+func (context *Log4AppenderContext) durableTick(log Log4Appender) {
+	policy := context.durability
+	if policy == nil || policy.syncInterval <= 0 || !policy.dirty {
+		return
+	}
+	if time.Since(policy.lastSync) >= policy.syncInterval {
+		context.durableSync(log)
+	}
+}
+
+// durableSync writes the buffer out and fsyncs it when records were written
+// since the last fsync.
+func (context *Log4AppenderContext) durableSync(log Log4Appender) {
+	policy := context.durability
+	if policy == nil || !policy.dirty {
+		return
+	}
+	appender, ok := log.(syncer)
+	if !ok {
+		return
+	}
+	policy.lastSync = time.Now()
+	if appender.sync() == nil {
+		policy.dirty = false
+	}
+}
+
+// tickInterval is the ticker period of Run, short enough for sync_interval.
+func (policy *durabilityPolicy) tickInterval() time.Duration {
+	if policy != nil && policy.syncInterval > 0 && policy.syncInterval < time.Second {
+		return policy.syncInterval
+	}
+	return time.Second
+}
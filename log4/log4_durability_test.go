@@ -0,0 +1,139 @@
+package log4
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// syncWriter keeps what a file appender writes and counts its fsyncs, a sync
+// takes delay.
+type syncWriter struct {
+	mutex sync.Mutex
+	data  []byte
+	// synced is the length of data at the last Sync
+	synced int
+	syncs  atomic.Int64
+	delay  time.Duration
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.data = append(w.data, p...)
+	return len(p), nil
+}
+
+func (w *syncWriter) Sync() error {
+	time.Sleep(w.delay)
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.synced = len(w.data)
+	w.syncs.Add(1)
+	return nil
+}
+
+func (w *syncWriter) state() (string, string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return string(w.data), string(w.data[:w.synced])
+}
+
+// newTestFile returns a file appender with durability writing into w, that
+// is not run.
+func newTestFile(t *testing.T, name string, w *syncWriter, durability Log4ConfigDurability) *Log4FileAppender {
+	path := filepath.Join(t.TempDir(), name+".log")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appender := NewLog4FileAppender(name, &Log4ConfigAppender{Kind: KindFile, Path: path, Pattern: "[%L] %M", Durability: durability}, file)
+	appender.out = w
+	appender.writer = NewLog4Writer(w)
+	appender.Context.writer = appender.writer
+	appender.Context.stats = &appenderStats{}
+	return appender
+}
+
+func TestDurabilityLevels(t *testing.T) {
+	w := &syncWriter{}
+	file := newTestFile(t, "test_durable_levels", w, Log4ConfigDurability{FlushLevel: "warn", SyncLevel: "error"})
+	defer file.File.Close()
+	context := &file.Context
+	cache := formatCacheType{}
+	write := func(level string, message string) {
+		BufferWriteAndDropRec(file, context, newLevelRecord(level, message), &cache)
+	}
+
+	write("INFO", "buffered")
+	if data, _ := w.state(); data != "" {
+		t.Fatalf("INFO written:%q", data)
+	}
+	write("WARN", "flushed")
+	if data, synced := w.state(); data != "[INFO] buffered\n[WARN] flushed\n" || synced != "" || w.syncs.Load() != 0 {
+		t.Fatalf("flush_level: data:%q synced:%q", data, synced)
+	}
+	write("ERROR", "synced")
+	if data, synced := w.state(); synced != data || w.syncs.Load() != 1 || context.stats.snapshot().Syncs != 1 {
+		t.Fatalf("sync_level: data:%q synced:%q syncs:%v", data, synced, w.syncs.Load())
+	}
+
+	// nothing written since, nothing to fsync
+	context.durableSync(file)
+	if w.syncs.Load() != 1 {
+		t.Fatalf("clean sync:%v", w.syncs.Load())
+	}
+}
+
+func TestDurabilitySyncInterval(t *testing.T) {
+	if interval := newDurabilityPolicy(&Log4ConfigAppender{Durability: Log4ConfigDurability{SyncInterval: "20ms"}}).tickInterval(); interval != 20*time.Millisecond {
+		t.Fatalf("tickInterval:%v", interval)
+	}
+	if interval := newDurabilityPolicy(&Log4ConfigAppender{Durability: Log4ConfigDurability{SyncInterval: "1m"}}).tickInterval(); interval != time.Second {
+		t.Fatalf("tickInterval:%v", interval)
+	}
+
+	w := &syncWriter{}
+	file := newTestFile(t, "test_durable_interval", w, Log4ConfigDurability{SyncInterval: "20ms"})
+	file.Run()
+	t.Cleanup(func() { file.Close(true) })
+
+	file.LogRecord(newLevelRecord("INFO", "ticked"))
+	waitFor(t, "the fsync of the ticker", func() bool {
+		_, synced := w.state()
+		return synced == "[INFO] ticked\n"
+	})
+	// no fsync without new records
+	syncs := w.syncs.Load()
+	time.Sleep(60 * time.Millisecond)
+	if w.syncs.Load() != syncs {
+		t.Fatalf("%v fsyncs while idle", w.syncs.Load()-syncs)
+	}
+}
+
+func TestDurabilityAudit(t *testing.T) {
+	w := &syncWriter{delay: 20 * time.Millisecond}
+	file := newTestFile(t, "test_durable_audit", w, Log4ConfigDurability{Audit: true})
+	file.Run()
+	t.Cleanup(func() { file.Close(true) })
+
+	want := ""
+	for _, message := range []string{"first", "second"} {
+		want += "[INFO] " + message + "\n"
+		start := time.Now()
+		file.LogRecord(newLevelRecord("INFO", message))
+		elapsed := time.Since(start)
+		if _, synced := w.state(); synced != want {
+			t.Fatalf("LogRecord(%v) returned before the fsync, synced:%q", message, synced)
+		}
+		if elapsed < w.delay {
+			t.Fatalf("LogRecord(%v) took %v", message, elapsed)
+		}
+	}
+	if w.syncs.Load() != 2 {
+		t.Fatalf("syncs:%v", w.syncs.Load())
+	}
+}
@@ -0,0 +1,86 @@
+package log4_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/yefy/log4go/log4"
+	"github.com/yefy/log4go/log4test"
+)
+
+func TestTargetEnabled(t *testing.T) {
+	rec := log4test.New(t, log4test.WithLevel("warn"))
+	target := rec.Target("")
+	for level, want := range map[log4.Level]bool{log4.DEBUG: false, log4.INFO: false, log4.WARNING: true, log4.ERROR: true} {
+		if target.Enabled(level) != want {
+			t.Errorf("Enabled(%v) = %v", level, !want)
+		}
+	}
+}
+
+func TestLazyMessages(t *testing.T) {
+	rec := log4test.New(t, log4test.WithLevel("warn"))
+	target := rec.Target("")
+
+	calls := 0
+	target.InfoFn(func() string { calls++; return "info" })
+	target.DebugFunc(func(e *log4.Entry) { calls++ })
+	if calls != 0 || len(rec.Records()) != 0 {
+		t.Fatalf("disabled levels: %v calls, records:%+v", calls, rec.Records())
+	}
+
+	_, file, line, _ := runtime.Caller(0)
+	target.WarnFn(func() string { calls++; return "built once" })
+	target.ErrorFunc(func(e *log4.Entry) {
+		calls++
+		e.With("user", "ann").Printf("failed:%v", 3)
+	})
+	if calls != 2 {
+		t.Fatalf("enabled levels: %v calls", calls)
+	}
+
+	records := rec.Records()
+	if len(records) != 2 {
+		t.Fatalf("records:%+v", records)
+	}
+	if records[0].Level != log4.WARNING || records[0].Message != "built once" {
+		t.Errorf("WarnFn:%+v", records[0])
+	}
+	if records[1].Level != log4.ERROR || records[1].Message != "failed:3" || len(records[1].Fields) != 1 || records[1].Fields[0].Value != "ann" {
+		t.Errorf("ErrorFunc:%+v", records[1])
+	}
+	for i, record := range records {
+		want := fmt.Sprintf("%v:%v@", filepath.Base(file), line+1+i)
+		if !strings.Contains(record.Source, want) {
+			t.Errorf("source:%q, want %v", record.Source, want)
+		}
+	}
+}
+
+func TestPackageLazyMessages(t *testing.T) {
+	rec := log4test.New(t, log4test.WithLevel("info"))
+	prev := log4.GLog4.Swap(rec.Log4())
+	defer log4.GLog4.Store(prev)
+
+	if log4.Enabled(log4.DEBUG) || !log4.Enabled(log4.INFO) {
+		t.Fatal("Enabled does not follow GLog4")
+	}
+	log4.DebugFn(func() string { t.Error("disabled DebugFn called"); return "" })
+	_, file, line, _ := runtime.Caller(0)
+	log4.InfoFn(func() string { return "package fn" })
+	log4.WarnFunc(func(e *log4.Entry) { e.Print("package func") })
+
+	records := rec.Records()
+	if len(records) != 2 || records[0].Message != "package fn" || records[1].Message != "package func" {
+		t.Fatalf("records:%+v", records)
+	}
+	for i, record := range records {
+		want := fmt.Sprintf("%v:%v@", filepath.Base(file), line+1+i)
+		if !strings.Contains(record.Source, want) {
+			t.Errorf("source:%q, want %v", record.Source, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package log4
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runFiles runs a Log4 whose root logs into two file appenders, it returns
+// their paths.
+func runFiles(t *testing.T) (*Log4, []string) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")}
+	l4 := NewLog4("")
+	err := l4.Run(&Log4Config{
+		Appenders: map[string]Log4ConfigAppender{
+			"a": {Kind: KindFile, Path: paths[0], Pattern: "[%L] %M"},
+			"b": {Kind: KindFile, Path: paths[1], Pattern: "[%L] %M"},
+		},
+		Root: Log4ConfigLogger{Level: "info", Appenders: []string{"a", "b"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l4.Close(true) })
+	return l4, paths
+}
+
+func readFiles(t *testing.T, paths []string) []string {
+	contents := make([]string, len(paths))
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[i] = string(data)
+	}
+	return contents
+}
+
+func TestFatalFlushesBeforeExit(t *testing.T) {
+	l4, paths := runFiles(t)
+	prevExit := osExit
+	defer func() { osExit = prevExit }()
+
+	code := -1
+	var atExit []string
+	osExit = func(c int) {
+		code = c
+		atExit = readFiles(t, paths)
+	}
+	l4.Target("root").Info("before")
+	l4.Target("root").Fatal("stopping:%v", 7)
+
+	if code != 1 {
+		t.Fatalf("osExit(%v)", code)
+	}
+	for i, content := range atExit {
+		if content != "[INFO] before\n[FATAL] stopping:7\n" {
+			t.Errorf("%v at exit:%q", paths[i], content)
+		}
+	}
+}
+
+func TestPanicFlushesAndPanics(t *testing.T) {
+	l4, paths := runFiles(t)
+	var contents []string
+	func() {
+		defer func() {
+			r := recover()
+			if r != "bad state:3" {
+				t.Errorf("recovered %v", r)
+			}
+			contents = readFiles(t, paths)
+		}()
+		l4.Target("root").Panic("bad state:%v", 3)
+		t.Error("Panic returned")
+	}()
+	for i, content := range contents {
+		if content != "[PANIC] bad state:3\n" {
+			t.Errorf("%v at panic:%q", paths[i], content)
+		}
+	}
+}
+
+func TestPackageFatalUsesGlobalLog4(t *testing.T) {
+	l4, paths := runFiles(t)
+	prev := GLog4.Swap(l4)
+	defer GLog4.Store(prev)
+	prevExit := osExit
+	defer func() { osExit = prevExit }()
+
+	var atExit []string
+	osExit = func(int) { atExit = readFiles(t, paths) }
+	Fatal("global")
+	if len(atExit) != 2 || !strings.HasSuffix(atExit[0], "[FATAL] global\n") || atExit[0] != atExit[1] {
+		t.Errorf("at exit:%q", atExit)
+	}
+}
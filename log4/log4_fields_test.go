@@ -0,0 +1,76 @@
+package log4_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/yefy/log4go/ee"
+	"github.com/yefy/log4go/log4"
+	"github.com/yefy/log4go/log4test"
+)
+
+func fieldMap(fields []ee.Attr) map[string]any {
+	m := make(map[string]any, len(fields))
+	for _, field := range fields {
+		m[field.Key] = field.Value
+	}
+	return m
+}
+
+func TestErrorArgFields(t *testing.T) {
+	recorder := log4test.New(t)
+	target := recorder.Target("")
+
+	err := ee.NewE(errors.New("timeout"), "query").With("table", "users").Code("DB_TIMEOUT")
+	wrapped := fmt.Errorf("load: %w", err)
+	target.Error("failed:%v", wrapped)
+	target.ErrorErr(errors.Join(errors.New("plain"), err), "joined, retry:%v", ee.With(errors.New("busy"), "retry", 2))
+	target.InfoFunc(func(e *log4.Entry) {
+		e.With("table", "override").Printf("func:%v", err)
+	})
+	target.Info("plain:%v %v", errors.New("no attrs"), 1)
+
+	records := recorder.Records()
+	if len(records) != 4 {
+		t.Fatalf("records:%+v", records)
+	}
+
+	fields := fieldMap(records[0].Fields)
+	if fields["table"] != "users" || fields["error_code"] != "DB_TIMEOUT" {
+		t.Errorf("Error args fields:%v", records[0].Fields)
+	}
+	fields = fieldMap(records[1].Fields)
+	if fields["table"] != "users" || fields["error_code"] != "DB_TIMEOUT" || fields["retry"] != 2 {
+		t.Errorf("ErrorErr fields:%v", records[1].Fields)
+	}
+	fields = fieldMap(records[2].Fields)
+	if fields["table"] != "override" || fields["error_code"] != "DB_TIMEOUT" {
+		t.Errorf("Entry fields:%v", records[2].Fields)
+	}
+	if len(records[3].Fields) != 0 {
+		t.Errorf("plain error fields:%v", records[3].Fields)
+	}
+}
+
+func TestErrorFrames(t *testing.T) {
+	recorder := log4test.New(t)
+	target := recorder.Target("")
+
+	err := ee.New(ee.New(errors.New("eof"), "read"), "load")
+	target.ErrorErr(err, "start failed:%v", 1)
+	target.LogErr(log4.WARNING, nil, "no error")
+
+	records := recorder.Records()
+	if len(records) != 2 {
+		t.Fatalf("records:%+v", records)
+	}
+	frames := records[0].ErrFrames
+	if records[0].Level != log4.ERROR || records[0].Message != "start failed:1" || len(frames) != 3 ||
+		frames[0].Message != "load" || frames[1].Message != "read" || frames[2].Message != "eof" {
+		t.Errorf("ErrorErr:%+v", records[0])
+	}
+	if records[1].Level != log4.WARNING || len(records[1].ErrFrames) != 0 {
+		t.Errorf("LogErr nil:%+v", records[1])
+	}
+}
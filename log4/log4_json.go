@@ -0,0 +1,136 @@
+package log4
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// jsonLayoutCodes are the pattern codes matching the json layout fields.
+const jsonLayoutCodes = "DTLCSMNE"
+
+const jsonTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// appendJson appends the record as one json object per line.
+func (layout *Log4Layout) appendJson(dst []byte, rec *Log4Record) []byte {
+	dst = append(dst, `{"time":"`...)
+	dst = rec.GetCreateTime(layout.IsUtc).AppendFormat(dst, jsonTimeLayout)
+	dst = append(dst, `","level":`...)
+	dst = appendJsonString(dst, rec.Level)
+	dst = append(dst, `,"target":`...)
+	dst = appendJsonString(dst, rec.Target)
+	if len(rec.File) > 0 {
+		var scratch [256]byte
+		source := layout.appendSource(scratch[:0], rec, layout.sourcePathKeep)
+		dst = append(dst, `,"source":`...)
+		dst = appendJsonString(dst, SliceByteToString(source))
+	}
+	dst = append(dst, `,"message":`...)
+	dst = appendJsonString(dst, rec.Message)
+	dst = append(dst, `,"seq":`...)
+	dst = strconv.AppendUint(dst, rec.Seq, 10)
+	if len(rec.Fields) > 0 {
+		dst = append(dst, `,"fields":{`...)
+		for i, field := range rec.Fields {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJsonString(dst, field.Key)
+			dst = append(dst, ':')
+			dst = appendJsonValue(dst, field.Value)
+		}
+		dst = append(dst, '}')
+	}
+	if len(rec.ErrFrames) > 0 {
+		dst = append(dst, `,"error":[`...)
+		for i, frame := range rec.ErrFrames {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, `{"source":`...)
+			dst = appendJsonString(dst, frame.Source)
+			dst = append(dst, `,"message":`...)
+			dst = appendJsonString(dst, frame.Message)
+			dst = append(dst, '}')
+		}
+		dst = append(dst, ']')
+	}
+	if len(rec.Stack) > 0 {
+		dst = append(dst, `,"stack":`...)
+		dst = appendJsonString(dst, rec.Stack)
+	}
+	dst = append(dst, "}\n"...)
+	return dst
+}
+
+// appendJsonValue appends value as json, falling back to its text when it
+// does not marshal.
+func appendJsonValue(dst []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return appendJsonString(dst, v)
+	case int:
+		return strconv.AppendInt(dst, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(dst, v, 10)
+	case uint64:
+		return strconv.AppendUint(dst, v, 10)
+	case bool:
+		return strconv.AppendBool(dst, v)
+	case nil:
+		return append(dst, "null"...)
+	case error:
+		return appendJsonString(dst, v.Error())
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return appendJsonString(dst, fmt.Sprint(value))
+	}
+	return append(dst, data...)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJsonString appends s as a quoted json string.
+func appendJsonString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				dst = append(dst, s[start:i]...)
+				dst = append(dst, `�`...)
+				i += size
+				start = i
+				continue
+			}
+			i += size
+			continue
+		}
+		if c >= 0x20 && c != '"' && c != '\\' {
+			i++
+			continue
+		}
+		dst = append(dst, s[start:i]...)
+		switch c {
+		case '"', '\\':
+			dst = append(dst, '\\', c)
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		case '\t':
+			dst = append(dst, '\\', 't')
+		default:
+			dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+		}
+		i++
+		start = i
+	}
+	dst = append(dst, s[start:]...)
+	dst = append(dst, '"')
+	return dst
+}
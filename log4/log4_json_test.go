@@ -0,0 +1,79 @@
+package log4
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/yefy/log4go/ee"
+)
+
+func TestJsonLayout(t *testing.T) {
+	rec := newBenchRecord()
+	defer rec.Put()
+	rec.Created = time.Date(2026, 10, 19, 9, 5, 7, 8000000, time.UTC)
+	rec.Message = "quote \" tab \t newline \n bad \xff end"
+	rec.Seq = 12
+	rec.File = "/src/app/db/query.go"
+	rec.Line = 42
+	rec.Func = "app/db.Query"
+	rec.Stack = "main.main()\n\t/src/main.go:5\n"
+
+	layout := NewLog4Layout("", Log4LayoutOptions{Layout: LayoutJson, SourcePath: SourcePathBase})
+	out := layout.AppendFormat(nil, rec, nil)
+	if out[len(out)-1] != '\n' {
+		t.Fatalf("no newline:%q", out)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(out, &decoded); err != nil {
+		t.Fatalf("%v in %s", err, out)
+	}
+	want := map[string]any{
+		"time":    "2026-10-19T09:05:07.008Z",
+		"level":   "INFO",
+		"target":  "main",
+		"source":  "query.go:42@Query",
+		"message": "quote \" tab \t newline \n bad � end",
+		"seq":     float64(12),
+		"stack":   rec.Stack,
+	}
+	if len(decoded) != len(want) {
+		t.Errorf("keys:%v", decoded)
+	}
+	for key, value := range want {
+		if decoded[key] != value {
+			t.Errorf("%v = %#v, want %#v", key, decoded[key], value)
+		}
+	}
+
+	// the record codes a json layout shows
+	for _, code := range []byte("DTLCSMNE") {
+		if !layout.UsesCode(code) {
+			t.Errorf("UsesCode(%c) false", code)
+		}
+	}
+	if layout.UsesCode('G') {
+		t.Error("UsesCode(G) true")
+	}
+}
+
+func TestJsonErrorFrames(t *testing.T) {
+	rec := newBenchRecord()
+	defer rec.Put()
+	rec.ErrFrames = []ee.Frame{{Source: "a/b.go:1@f", Message: "read"}, {Message: "eof"}}
+
+	out := NewLog4Layout("", Log4LayoutOptions{Layout: LayoutJson}).AppendFormat(nil, rec, nil)
+	var decoded struct {
+		Error []struct {
+			Source  string `json:"source"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(out, &decoded); err != nil {
+		t.Fatalf("%v in %s", err, out)
+	}
+	if len(decoded.Error) != 2 || decoded.Error[0].Source != "a/b.go:1@f" || decoded.Error[0].Message != "read" ||
+		decoded.Error[1].Source != "" || decoded.Error[1].Message != "eof" {
+		t.Errorf("error:%+v", decoded.Error)
+	}
+}
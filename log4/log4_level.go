@@ -1,65 +1,265 @@
 package log4
 
-import "github.com/yefy/log4go/ee"
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/yefy/log4go/ee"
+)
 
 type Level int
 
+// Built-in levels are spaced by 10 so that RegisterLevel can put custom ones
+// in between, e.g. NOTICE at INFO+5.
 const (
-	FINE Level = iota
-	TRACE
-	DEBUG
-	INFO
-	WARNING
-	ERROR
-	CRITICAL
+	FINE     Level = 10
+	TRACE    Level = 20
+	DEBUG    Level = 30
+	INFO     Level = 40
+	WARNING  Level = 50
+	ERROR    Level = 60
+	CRITICAL Level = 70
+	PANIC    Level = 80
+	FATAL    Level = 90
 )
 
-var (
-	levelConfigStrings = [...]string{"fine", "trace", "debug", "info", "warn", "error", "crit"}
+// ALL and OFF bound every level, a target at ALL logs everything and one at
+// OFF nothing.
+const (
+	ALL Level = math.MinInt32
+	OFF Level = math.MaxInt32
 )
 
-var levelConfigMap = map[string]Level{
-	levelConfigStrings[FINE]:     FINE,
-	levelConfigStrings[TRACE]:    TRACE,
-	levelConfigStrings[DEBUG]:    DEBUG,
-	levelConfigStrings[INFO]:     INFO,
-	levelConfigStrings[WARNING]:  WARNING,
-	levelConfigStrings[ERROR]:    ERROR,
-	levelConfigStrings[CRITICAL]: CRITICAL,
-}
+var (
+	levelConfigStrings = map[Level]string{ALL: "all", FINE: "fine", TRACE: "trace", DEBUG: "debug", INFO: "info", WARNING: "warn", ERROR: "error", CRITICAL: "crit", PANIC: "panic", FATAL: "fatal", OFF: "off"}
+)
 
 var (
-	levelFileStrings = [...]string{"FINE", "TRACE", "DEBUG", "INFO", "WARN", "ERROR", "CRIT"}
+	levelFileStrings = map[Level]string{ALL: "ALL", FINE: "FINE", TRACE: "TRACE", DEBUG: "DEBUG", INFO: "INFO", WARNING: "WARN", ERROR: "ERROR", CRITICAL: "CRIT", PANIC: "PANIC", FATAL: "FATAL", OFF: "OFF"}
 )
 
-var levelFileMap = map[Level]string{
-	FINE:     levelFileStrings[FINE],
-	TRACE:    levelFileStrings[TRACE],
-	DEBUG:    levelFileStrings[DEBUG],
-	INFO:     levelFileStrings[INFO],
-	WARNING:  levelFileStrings[WARNING],
-	ERROR:    levelFileStrings[ERROR],
-	CRITICAL: levelFileStrings[CRITICAL],
+// levelAliases are accepted by LevelNameToLevel besides the config names.
+// "fatal" was an alias of CRITICAL until the FATAL level, it now names FATAL:
+// a logger at "fatal" no longer logs CRIT and PANIC records.
+var levelAliases = map[string]Level{
+	"verbose":     FINE,
+	"information": INFO,
+	"warning":     WARNING,
+	"err":         ERROR,
+	"critical":    CRITICAL,
+}
+
+// levelTable is replaced as a whole by RegisterLevel so that lookups on the
+// logging path need no lock.
+type levelTable struct {
+	configMap   map[string]Level
+	fileMap     map[Level]string
+	fileNameMap map[string]Level
+	// levels is sorted ascending
+	levels []Level
+}
+
+var levelTablePtr atomic.Pointer[levelTable]
+
+var levelMutex sync.Mutex
+
+func init() {
+	table := &levelTable{
+		configMap:   make(map[string]Level),
+		fileMap:     make(map[Level]string),
+		fileNameMap: make(map[string]Level),
+	}
+	for level, name := range levelConfigStrings {
+		table.add(level, name, levelFileStrings[level])
+	}
+	table.sort()
+	levelTablePtr.Store(table)
+}
+
+func (table *levelTable) add(level Level, configName string, fileName string) {
+	table.configMap[configName] = level
+	table.fileMap[level] = fileName
+	table.fileNameMap[fileName] = level
+	table.levels = append(table.levels, level)
+}
+
+func (table *levelTable) sort() {
+	sort.Slice(table.levels, func(i, j int) bool {
+		return table.levels[i] < table.levels[j]
+	})
+}
+
+func (table *levelTable) clone() *levelTable {
+	clone := &levelTable{
+		configMap:   make(map[string]Level, len(table.configMap)+1),
+		fileMap:     make(map[Level]string, len(table.fileMap)+1),
+		fileNameMap: make(map[string]Level, len(table.fileNameMap)+1),
+		levels:      append([]Level(nil), table.levels...),
+	}
+	for k, v := range table.configMap {
+		clone.configMap[k] = v
+	}
+	for k, v := range table.fileMap {
+		clone.fileMap[k] = v
+	}
+	for k, v := range table.fileNameMap {
+		clone.fileNameMap[k] = v
+	}
+	return clone
+}
+
+// RegisterLevel adds a custom level usable in the yaml level fields under
+// configName and printed by %L as fileName, e.g.
+// RegisterLevel(INFO+5, "notice", "NOTICE"). Register levels before InitFile.
+func RegisterLevel(level Level, configName string, fileName string) error {
+	if len(configName) <= 0 || len(fileName) <= 0 {
+		return ee.New(nil, "empty level name, configName:%v, fileName:%v", configName, fileName)
+	}
+	configName = strings.ToLower(configName)
+
+	levelMutex.Lock()
+	defer levelMutex.Unlock()
+
+	table := levelTablePtr.Load()
+	if name, ok := table.fileMap[level]; ok {
+		return ee.New(nil, "level:%d already registered as %v", level, name)
+	}
+	if _, ok := table.configMap[configName]; ok {
+		return ee.New(nil, "level configName:%v already registered", configName)
+	}
+	if _, ok := levelAliases[configName]; ok {
+		return ee.New(nil, "level configName:%v is an alias", configName)
+	}
+	if _, ok := table.fileNameMap[fileName]; ok {
+		return ee.New(nil, "level fileName:%v already registered", fileName)
+	}
+
+	table = table.clone()
+	table.add(level, configName, fileName)
+	table.sort()
+	levelTablePtr.Store(table)
+	return nil
+}
+
+// Levels returns the registered levels in ascending order.
+func Levels() []Level {
+	return append([]Level(nil), levelTablePtr.Load().levels...)
 }
 
+// LevelNameToLevel accepts the config names and their aliases in any case,
+// unknown names get a "did you mean" suggestion.
 func LevelNameToLevel(name string) (Level, error) {
-	level, ok := levelConfigMap[name]
-	if !ok {
-		return ERROR, ee.New(nil, "not find levelName:%v, use:%+v", name, levelConfigMap)
+	table := levelTablePtr.Load()
+	lowerName := strings.ToLower(strings.TrimSpace(name))
+	level, ok := table.configMap[lowerName]
+	if ok {
+		return level, nil
+	}
+	level, ok = levelAliases[lowerName]
+	if ok {
+		return level, nil
 	}
 
-	return level, nil
+	names := make([]string, 0, len(table.levels))
+	for _, level := range table.levels {
+		for configName, configLevel := range table.configMap {
+			if configLevel == level {
+				names = append(names, configName)
+			}
+		}
+	}
+	suggestion := closestLevelName(lowerName, table)
+	if len(suggestion) > 0 {
+		return ERROR, ee.New(nil, "not find levelName:%v, did you mean %v? use:%+v", name, suggestion, names)
+	}
+	return ERROR, ee.New(nil, "not find levelName:%v, use:%+v", name, names)
 }
 
+// LevelNameToLevelDef falls back to ERROR and warns about it, Log4Config.Check
+// rejects bad names before they get here.
 func LevelNameToLevelDef(name string) Level {
 	level, err := LevelNameToLevel(name)
 	if err != nil {
+		log4Warn("level falls back to %v, err:%v", LevelToLevelFileName(ERROR), err)
 		return ERROR
 	}
 
 	return level
 }
 
+// closestLevelName returns the config name or alias closest to name, "" when
+// none is within 2 edits.
+func closestLevelName(name string, table *levelTable) string {
+	best := ""
+	bestDistance := 3
+	check := func(candidate string) {
+		distance := editDistance(name, candidate)
+		if distance < bestDistance || (distance == bestDistance && len(best) > 0 && candidate < best) {
+			best = candidate
+			bestDistance = distance
+		}
+	}
+	for configName := range table.configMap {
+		check(configName)
+	}
+	for alias := range levelAliases {
+		check(alias)
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance of a and b.
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// LevelToLevelFileName is the %L name of level, Level(45) for a level that
+// is not registered.
 func LevelToLevelFileName(level Level) string {
-	return levelFileMap[level]
+	name, ok := levelTablePtr.Load().fileMap[level]
+	if !ok {
+		return "Level(" + strconv.Itoa(int(level)) + ")"
+	}
+	return name
+}
+
+// LevelFileNameToLevel maps a %L name back to its level, Level(45) included.
+func LevelFileNameToLevel(fileName string) (Level, bool) {
+	level, ok := levelTablePtr.Load().fileNameMap[fileName]
+	if ok {
+		return level, true
+	}
+	number, ok := strings.CutPrefix(fileName, "Level(")
+	if !ok || !strings.HasSuffix(number, ")") {
+		return 0, false
+	}
+	value, err := strconv.Atoi(strings.TrimSuffix(number, ")"))
+	if err != nil {
+		return 0, false
+	}
+	return Level(value), true
+}
+
+func (level Level) String() string {
+	return LevelToLevelFileName(level)
 }
@@ -0,0 +1,165 @@
+package log4
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnregisteredLevelName(t *testing.T) {
+	level := Level(45)
+	name := LevelToLevelFileName(level)
+	if name != "Level(45)" || level.String() != name {
+		t.Fatalf("LevelToLevelFileName(45) = %q, String = %q", name, level.String())
+	}
+	parsed, ok := LevelFileNameToLevel(name)
+	if !ok || parsed != level {
+		t.Fatalf("LevelFileNameToLevel(%q) = %v, %v", name, parsed, ok)
+	}
+	if _, ok := LevelFileNameToLevel("Level(x)"); ok {
+		t.Fatalf("LevelFileNameToLevel(Level(x)) ok")
+	}
+
+	rec := newBenchRecord()
+	defer rec.Put()
+	rec.Level = name
+	cache := Log4LayoutCache{}
+	got := string(CompileLayout("[%L] %M").AppendFormat(nil, rec, &cache))
+	if got != "[Level(45)] i:12345\n" {
+		t.Fatalf("pattern = %q", got)
+	}
+	got = string(NewLog4Layout("", Log4LayoutOptions{Layout: LayoutJson}).AppendFormat(nil, rec, &cache))
+	if !strings.Contains(got, `"level":"Level(45)"`) {
+		t.Fatalf("json = %q", got)
+	}
+}
+
+func TestRegisterLevel(t *testing.T) {
+	notice := WARNING + 3
+	// levels stay registered, e.g. with -count=2
+	err := RegisterLevel(notice, "Notice_Test", "NOTE")
+	if err != nil && notice.String() != "NOTE" {
+		t.Fatal(err)
+	}
+	level, err := LevelNameToLevel("notice_test")
+	if err != nil || level != notice || notice.String() != "NOTE" {
+		t.Fatalf("LevelNameToLevel = %v, %v, String = %v", level, err, notice)
+	}
+	if parsed, ok := LevelFileNameToLevel("NOTE"); !ok || parsed != notice {
+		t.Errorf("LevelFileNameToLevel(NOTE) = %v, %v", parsed, ok)
+	}
+	levels := Levels()
+	for i := 1; i < len(levels); i++ {
+		if levels[i-1] >= levels[i] {
+			t.Fatalf("Levels not sorted:%v", levels)
+		}
+	}
+	// a custom level takes the color of the closest level below it
+	if color, ok := levelColor("NOTE"); !ok || color != levelColorMap[WARNING] {
+		t.Errorf("levelColor(NOTE) = %q, %v", color, ok)
+	}
+
+	for _, bad := range []struct {
+		level      Level
+		configName string
+		fileName   string
+	}{
+		{notice, "other_test", "OTHER"},
+		{notice + 1, "notice_test", "OTHER"},
+		{notice + 1, "warning", "OTHER"},
+		{notice + 1, "other_test", "NOTE"},
+		{notice + 1, "", "OTHER"},
+	} {
+		if RegisterLevel(bad.level, bad.configName, bad.fileName) == nil {
+			t.Errorf("RegisterLevel(%v, %q, %q) accepted", bad.level, bad.configName, bad.fileName)
+		}
+	}
+
+	rec := newBenchRecord()
+	defer rec.Put()
+	rec.Level = notice.String()
+	if got := string(CompileLayout("[%L] %M").AppendFormat(nil, rec, nil)); got != "[NOTE] i:12345\n" {
+		t.Errorf("pattern = %q", got)
+	}
+
+	config := Log4Config{Root: Log4ConfigLogger{Level: "notice_test"}}
+	if err := config.Check(); err != nil {
+		t.Errorf("Check level notice_test:%v", err)
+	}
+}
+
+func TestLevelNameToLevel(t *testing.T) {
+	tests := map[string]Level{
+		"info":        INFO,
+		"INFO":        INFO,
+		" Warn ":      WARNING,
+		"warning":     WARNING,
+		"WARNING":     WARNING,
+		"critical":    CRITICAL,
+		"crit":        CRITICAL,
+		"err":         ERROR,
+		"verbose":     FINE,
+		"information": INFO,
+		"off":         OFF,
+		"ALL":         ALL,
+	}
+	for name, want := range tests {
+		level, err := LevelNameToLevel(name)
+		if err != nil || level != want {
+			t.Errorf("LevelNameToLevel(%q) = %v, %v, want %v", name, level, err, want)
+		}
+	}
+
+	_, err := LevelNameToLevel("wran")
+	if err == nil || !strings.Contains(err.Error(), "did you mean warn?") {
+		t.Errorf("wran: %v", err)
+	}
+	_, err = LevelNameToLevel("loud")
+	if err == nil || strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("loud: %v", err)
+	}
+	if level := LevelNameToLevelDef("loud"); level != ERROR {
+		t.Errorf("LevelNameToLevelDef(loud) = %v", level)
+	}
+	if ALL >= FINE || OFF <= FATAL {
+		t.Error("ALL and OFF do not bound the levels")
+	}
+}
+
+func TestOffAndAllLevels(t *testing.T) {
+	l4, buf := runLog4(t, "[%L] %M", Log4ConfigLogger{Level: "off"})
+	l4.Target("root").Critical("silenced")
+	if lines := output(l4, buf); len(lines) != 1 || lines[0] != "" {
+		t.Errorf("off logged:%q", lines)
+	}
+
+	l4, buf = runLog4(t, "[%L] %M", Log4ConfigLogger{Level: "all"})
+	l4.Target("root").Fine("opened")
+	l4.Target("root").Log(Level(1), "below fine")
+	if lines := output(l4, buf); len(lines) != 2 || lines[0] != "[FINE] opened" || lines[1] != "[Level(1)] below fine" {
+		t.Errorf("all logged:%q", lines)
+	}
+}
+
+// TestFatalLevelName checks that fatal names the FATAL level, not CRITICAL
+// as the alias did before the level existed.
+func TestFatalLevelName(t *testing.T) {
+	level, err := LevelNameToLevel("fatal")
+	if err != nil || level != FATAL {
+		t.Fatalf("LevelNameToLevel(fatal) = %v, %v", level, err)
+	}
+	if level, _ := LevelNameToLevel("critical"); level != CRITICAL {
+		t.Errorf("critical = %v", level)
+	}
+	if !(CRITICAL < PANIC && PANIC < FATAL) {
+		t.Error("PANIC and FATAL not above CRITICAL")
+	}
+
+	l4, buf := runLog4(t, "[%L] %M", Log4ConfigLogger{Level: "fatal"})
+	target := l4.Target("root")
+	target.Critical("dropped")
+	target.Log(PANIC, "dropped too")
+	target.Log(FATAL, "kept")
+	if lines := output(l4, buf); len(lines) != 1 || lines[0] != "[FATAL] kept" {
+		t.Errorf("logger at fatal:%q", lines)
+	}
+}
@@ -2,11 +2,11 @@ package log4
 
 import (
 	"context"
-	"fmt"
+	"github.com/yefy/log4go/ee"
+	"io"
 	"os"
 	"runtime/debug"
 	"sort"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -88,9 +88,13 @@ func init() {
 
 type Log4Appender interface {
 	Name() string
+	Layout() *Log4Layout
 	LogRecord(rec *Log4Record)
 	Run()
 	Flush()
+	// FlushSync writes out the queued records and the buffer and waits for
+	// it, false when it timed out or the appender is closed.
+	FlushSync(timeout time.Duration) bool
 	Close(isWait bool)
 	BufferWrite(msg string) error
 	BufferFlush() error
@@ -100,11 +104,49 @@ type Log4Appender interface {
 
 func BufferWriteAndDropRec(log Log4Appender, context *Log4AppenderContext, rec *Log4Record, formatCache *formatCacheType) {
 	defer rec.Put()
-	msg := FormatLogRecord(context.Appender.Pattern, context.IsUtc, rec, formatCache)
-	if len(msg) > 0 {
+	context.space.check(context.name, false)
+	if !context.space.allows(rec) {
+		if context.space.mode == LowSpaceStop {
+			context.stats.dropped.Add(1)
+		} else {
+			context.stats.filtered.Add(1)
+		}
+		return
+	}
+	bufp := layoutBufPool.Get().(*[]byte)
+	defer layoutBufPool.Put(bufp)
+
+	var buf []byte
+	if rec.Raw != nil {
+		buf = append((*bufp)[:0], rec.Raw...)
+	} else {
+		multiline := rec.Multiline
+		if len(context.Multiline.Policy) > 0 {
+			multiline = context.Multiline
+		}
+		buf = context.Layout.appendFormat((*bufp)[:0], rec, formatCache, context.IsUtc, multiline)
+		if len(buf) > 0 && context.IsColor {
+			buf = AppendColorized(buf, rec.Level)
+		}
+	}
+	if len(buf) > 0 {
 		recordCountStatAdd(context.nameWrite)
-		log.BufferWrite(msg)
+		context.pending.add(rec, len(buf))
+		err := log.BufferWrite(SliceByteToString(buf))
+		if err == nil {
+			context.durableWrite(log, rec)
+		}
+	} else {
+		context.stats.filtered.Add(1)
 	}
+	*bufp = buf
+}
+
+var layoutBufPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 256)
+		return &buf
+	},
 }
 
 func BufferFlush(log Log4Appender, context *Log4AppenderContext, formatCache *formatCacheType) {
@@ -118,6 +160,7 @@ func BufferFlush(log Log4Appender, context *Log4AppenderContext, formatCache *fo
 				return
 			}
 			recordCountStatAdd(context.nameValid)
+			context.stats.dequeue()
 			BufferWriteAndDropRec(log, context, rec, formatCache)
 		default:
 			return
@@ -125,11 +168,41 @@ func BufferFlush(log Log4Appender, context *Log4AppenderContext, formatCache *fo
 	}
 }
 
+// BufferFlushSync waits for the queued records and the buffer to be written
+// out, timeout <= 0 waits until they are or the appender is closed.
+func BufferFlushSync(context *Log4AppenderContext, timeout time.Duration) bool {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	done := context.context.Ctx.Done()
+	flushed := make(chan struct{})
+	select {
+	case context.flushChan <- flushed:
+	case <-done:
+		return false
+	case <-expired:
+		return false
+	}
+
+	select {
+	case <-flushed:
+		return true
+	case <-done:
+		return false
+	case <-expired:
+		return false
+	}
+}
+
 func Run(log Log4Appender, context *Log4AppenderContext) {
 	context.context.Add(1)
 	go func() {
 		recordCountStatAdd(runThreadCount)
-		ticker := time.NewTicker(1 * time.Second)
+		ticker := time.NewTicker(context.durability.tickInterval())
 		formatCache := formatCacheType{}
 		defer func() {
 			ticker.Stop()
@@ -140,6 +213,7 @@ func Run(log Log4Appender, context *Log4AppenderContext) {
 			context.context.Done()
 		}()
 
+		context.space.check(context.name, true)
 		done := context.context.Ctx.Done()
 		writeCount := 0
 		lastWriteCount := writeCount
@@ -153,6 +227,7 @@ func Run(log Log4Appender, context *Log4AppenderContext) {
 					return
 				}
 				recordCountStatAdd(context.nameValid)
+				context.stats.dequeue()
 				BufferWriteAndDropRec(log, context, rec, &formatCache)
 				writeCount += 1
 				if lastBufferSize == 0 {
@@ -162,13 +237,22 @@ func Run(log Log4Appender, context *Log4AppenderContext) {
 					isWrite = true
 				}
 				lastBufferSize = log.BufferSize()
+				if context.flushOnIdle && len(context.recChan) == 0 {
+					log.BufferFlush()
+				}
 			case <-done:
 				log4Debug("record %v done", context.name)
 				BufferFlush(log, context, &formatCache)
 				return
-			case <-context.flushChan:
+			case flushed := <-context.flushChan:
 				BufferFlush(log, context, &formatCache)
+				if flushed != nil {
+					context.durableSync(log)
+					close(flushed)
+				}
 			case <-ticker.C:
+				context.space.check(context.name, false)
+				context.durableTick(log)
 				if lastWriteCount == writeCount {
 					if log.BufferSize() > 0 {
 						log.BufferFlush()
@@ -200,12 +284,33 @@ type Log4AppenderContext struct {
 	Appender        *Log4ConfigAppender
 	recChan         chan *Log4Record
 	context         *WaitGroupContext
-	flushChan       chan bool
+	flushChan       chan chan struct{} // a non nil channel is closed once flushed
+	Layout          *Log4Layout
 	IsUtc           bool
+	IsColor         bool
+	// writer is the buffer of the appender, onError handles its failures
+	writer  *Log4Writer
+	onError onErrorPolicy
+	// failedAt is the time of the last failed write in unix nanoseconds, 0
+	// once a write succeeds
+	failedAt atomic.Int64
+	// stats are shared by the appenders of this name across reloads, pending
+	// are the records in the buffer not counted in them yet
+	stats   *appenderStats
+	pending pendingWrites
+	// space degrades a file appender low on disk space, nil when unset
+	space *spaceGuard
+	// durability flushes and fsyncs a file appender, nil when unset
+	durability *durabilityPolicy
+	// Multiline overrides the policy of the records when set
+	Multiline Log4Multiline
+	// flushOnIdle flushes as soon as recChan is drained instead of waiting
+	// for the ticker, used by the console where latency matters more.
+	flushOnIdle bool
 }
 
 func NewLog4FileAppender(name string, Appender *Log4ConfigAppender, file *os.File) *Log4FileAppender {
-	isUtc := strings.Contains(Appender.Pattern, FORMAT_TIME_UTC)
+	layout := NewLog4Layout(Appender.Pattern, Appender.LayoutOptions())
 	writer := NewLog4Writer(file)
 	return &Log4FileAppender{
 		Context: Log4AppenderContext{
@@ -220,29 +325,56 @@ func NewLog4FileAppender(name string, Appender *Log4ConfigAppender, file *os.Fil
 			Appender:        Appender,
 			recChan:         make(chan *Log4Record, 1024),
 			context:         NewWaitGroupContext(),
-			flushChan:       make(chan bool, 10),
-			IsUtc:           isUtc,
+			flushChan:       make(chan chan struct{}, 10),
+			Layout:          layout,
+			IsUtc:           layout.IsUtc,
+			Multiline:       ParseMultilineDef(Appender.Multiline, Appender.MultilineMarker),
+			stats:           getAppenderStats(name),
+			writer:          writer,
+			onError:         newOnErrorPolicy(Appender),
+			space:           newSpaceGuard(Appender),
+			durability:      newDurabilityPolicy(Appender),
 		},
 		File:   file,
+		out:    file,
 		writer: writer,
 	}
 }
 
+// writeSyncer is what a file appender writes to and fsyncs, its File.
+type writeSyncer interface {
+	io.Writer
+	Sync() error
+}
+
 type Log4FileAppender struct {
 	Context Log4AppenderContext
 	File    *os.File
 
+	out    writeSyncer
 	writer *Log4Writer
 }
 
+func (log *Log4FileAppender) appenderContext() *Log4AppenderContext {
+	return &log.Context
+}
+
 func (log *Log4FileAppender) Name() string {
 	return log.Context.name
 }
 
+func (log *Log4FileAppender) Layout() *Log4Layout {
+	return log.Context.Layout
+}
+
 func (log *Log4FileAppender) LogRecord(rec *Log4Record) {
 	recordCountStatAdd(log.Context.nameRecordStart)
+	log.Context.stats.enqueue()
 	log.Context.recChan <- rec
 	recordCountStatAdd(log.Context.nameRecordEnd)
+	if log.Context.durability.isAudit() {
+		BufferFlushSync(&log.Context, 0)
+	}
 }
 
 func (log *Log4FileAppender) Run() {
@@ -253,35 +385,52 @@ func (log *Log4FileAppender) BufferWrite(msg string) error {
 	_, err := log.writer.WriteString(msg)
 	if err != nil {
 		log4Debug("log.writer.WriteString err:%v", err)
-		return err
 	}
-	return nil
+	return log.Context.settle(err, log.writer.Buffered())
 }
 
 func (log *Log4FileAppender) BufferFlush() error {
 	if log.BufferSize() > 0 {
 		recordCountStatAdd(log.Context.nameFlush)
+		log.Context.stats.flushes.Add(1)
 		err := log.writer.Flush()
 		if err != nil {
 			log4Debug("log.writer.Flush err:%v", err)
-			return err
 		}
+		return log.Context.settle(err, log.writer.Buffered())
 	}
 	return nil
 }
 
-func (log *Log4FileAppender) BufferClose() error {
+// sync writes the buffer out and fsyncs the file.
+func (log *Log4FileAppender) sync() error {
 	err := log.BufferFlush()
 	if err != nil {
 		return err
 	}
+	log.Context.stats.syncs.Add(1)
+	err = log.out.Sync()
+	if err != nil {
+		log4Debug("log.out.Sync err:%v", err)
+		return log.Context.writeResult(ee.New(err, "log.out.Sync"))
+	}
+	return nil
+}
+
+func (log *Log4FileAppender) BufferClose() error {
+	var flushErr error
+	if log.Context.durability != nil {
+		flushErr = log.sync()
+	} else {
+		flushErr = log.BufferFlush()
+	}
 	recordCountStatAdd(log.Context.nameClose)
-	err = log.File.Close()
+	err := log.File.Close()
 	if err != nil {
 		log4Debug("log.File.Close err:%v", err)
 		return err
 	}
-	return nil
+	return flushErr
 }
 
 func (log *Log4FileAppender) BufferSize() int {
@@ -289,7 +438,11 @@ func (log *Log4FileAppender) BufferSize() int {
 }
 
 func (log *Log4FileAppender) Flush() {
-	log.Context.flushChan <- true
+	log.Context.flushChan <- nil
+}
+
+func (log *Log4FileAppender) FlushSync(timeout time.Duration) bool {
+	return BufferFlushSync(&log.Context, timeout)
 }
 
 func (log *Log4FileAppender) Close(isWait bool) {
@@ -297,7 +450,12 @@ func (log *Log4FileAppender) Close(isWait bool) {
 }
 
 func NewLog4ConsoleAppender(name string, Appender *Log4ConfigAppender) *Log4ConsoleAppender {
-	isUtc := strings.Contains(Appender.Pattern, FORMAT_TIME_UTC)
+	layout := NewLog4Layout(Appender.Pattern, Appender.LayoutOptions())
+	file := os.Stdout
+	if Appender.Stream == ConsoleStreamStderr {
+		file = os.Stderr
+	}
+	writer := NewLog4Writer(file)
 	return &Log4ConsoleAppender{
 		Context: Log4AppenderContext{
 			name:            name,
@@ -311,22 +469,43 @@ func NewLog4ConsoleAppender(name string, Appender *Log4ConfigAppender) *Log4Cons
 			Appender:        Appender,
 			recChan:         make(chan *Log4Record, 1024),
 			context:         NewWaitGroupContext(),
-			flushChan:       make(chan bool, 10),
-			IsUtc:           isUtc,
+			flushChan:       make(chan chan struct{}, 10),
+			Layout:          layout,
+			IsUtc:           layout.IsUtc,
+			Multiline:       ParseMultilineDef(Appender.Multiline, Appender.MultilineMarker),
+			stats:           getAppenderStats(name),
+			writer:          writer,
+			onError:         newOnErrorPolicy(Appender),
+			IsColor:         IsColorEnabled(Appender.Color, file),
+			flushOnIdle:     true,
 		},
+		File:   file,
+		writer: writer,
 	}
 }
 
 type Log4ConsoleAppender struct {
 	Context Log4AppenderContext
+	File    *os.File
+
+	writer *Log4Writer
+}
+
+func (log *Log4ConsoleAppender) appenderContext() *Log4AppenderContext {
+	return &log.Context
 }
 
 func (log *Log4ConsoleAppender) Name() string {
 	return log.Context.name
 }
 
+func (log *Log4ConsoleAppender) Layout() *Log4Layout {
+	return log.Context.Layout
+}
+
 func (log *Log4ConsoleAppender) LogRecord(rec *Log4Record) {
 	recordCountStatAdd(log.Context.nameRecordStart)
+	log.Context.stats.enqueue()
 	log.Context.recChan <- rec
 	recordCountStatAdd(log.Context.nameRecordEnd)
 }
@@ -336,26 +515,45 @@ func (log *Log4ConsoleAppender) Run() {
 }
 
 func (log *Log4ConsoleAppender) BufferWrite(msg string) error {
-	fmt.Printf("%v", msg)
-	return nil
+	_, err := log.writer.WriteString(msg)
+	if err != nil {
+		log4Debug("log.writer.WriteString err:%v", err)
+	}
+	return log.Context.settle(err, log.writer.Buffered())
 }
 
 func (log *Log4ConsoleAppender) BufferFlush() error {
-	//recordCountStatAdd(log.Context.nameFlush)
+	if log.BufferSize() > 0 {
+		recordCountStatAdd(log.Context.nameFlush)
+		log.Context.stats.flushes.Add(1)
+		err := log.writer.Flush()
+		if err != nil {
+			log4Debug("log.writer.Flush err:%v", err)
+		}
+		return log.Context.settle(err, log.writer.Buffered())
+	}
 	return nil
 }
 
 func (log *Log4ConsoleAppender) BufferClose() error {
+	err := log.BufferFlush()
+	if err != nil {
+		return err
+	}
 	recordCountStatAdd(log.Context.nameClose)
 	return nil
 }
 
 func (log *Log4ConsoleAppender) BufferSize() int {
-	return 0
+	return log.writer.Buffered()
 }
 
 func (log *Log4ConsoleAppender) Flush() {
-	log.Context.flushChan <- true
+	log.Context.flushChan <- nil
+}
+
+func (log *Log4ConsoleAppender) FlushSync(timeout time.Duration) bool {
+	return BufferFlushSync(&log.Context, timeout)
 }
 
 func (log *Log4ConsoleAppender) Close(isWait bool) {
@@ -377,6 +575,23 @@ type Log4Record struct {
 	CreatedUtc time.Time
 	Source     string
 	Message    string
+	// File, Line and Func locate the caller, Func is fully qualified. They
+	// are only set when a layout of the target shows the source.
+	File        string
+	Line        int
+	Func        string
+	Seq         uint64
+	GoroutineId int64
+	// Stack is set for records at or above the stacktrace_level of the logger
+	Stack string
+	// ErrFrames is the error chain of the XxxErr functions
+	ErrFrames []ee.Frame
+	// Fields are key/values of the record, from Entry.With and ee attributes
+	Fields []ee.Attr
+	// Multiline is the policy of the logger, an appender one overrides it
+	Multiline Log4Multiline
+	// Raw is written as-is instead of the formatted record, see NewRawRecord
+	Raw []byte
 }
 
 func (record *Log4Record) GetCreateTime(isUtc bool) time.Time {
@@ -0,0 +1,92 @@
+package log4
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is written by the goroutine of an appender while a test reads it.
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+// waitFor polls cond for up to a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %v", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// runConsole runs a console appender writing into the returned buffer
+// instead of its stream, it is closed by t.Cleanup.
+func runConsole(t *testing.T, name string, appender *Log4ConfigAppender) (*Log4ConsoleAppender, *syncBuffer) {
+	buf := &syncBuffer{}
+	console := NewLog4ConsoleAppender(name, appender)
+	console.writer = NewLog4Writer(buf)
+	console.Context.writer = console.writer
+	console.Run()
+	t.Cleanup(func() { console.Close(true) })
+	return console, buf
+}
+
+func TestConsoleFlushesOnIdle(t *testing.T) {
+	console, buf := runConsole(t, "test_console_idle", &Log4ConfigAppender{Kind: KindConsole, Pattern: "[%L] %M", Color: ConsoleColorNever})
+	rec := newBenchRecord()
+	console.LogRecord(rec)
+	// well before the one second ticker
+	start := time.Now()
+	waitFor(t, "the record", func() bool { return buf.String() == "[INFO] i:12345\n" })
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("flushed after %v", elapsed)
+	}
+}
+
+// runLog4 runs a Log4 whose root logs into the returned buffer with pattern,
+// root sets the other logger options. It is closed by t.Cleanup.
+func runLog4(t *testing.T, pattern string, root Log4ConfigLogger) (*Log4, *syncBuffer) {
+	t.Helper()
+	console := NewLog4ConsoleAppender("test_buffer", &Log4ConfigAppender{Kind: KindConsole, Pattern: pattern, Color: ConsoleColorNever})
+	buf := &syncBuffer{}
+	console.writer = NewLog4Writer(buf)
+	console.Context.writer = console.writer
+
+	l4 := NewLog4("")
+	l4.AddAppender(console)
+	if len(root.Level) <= 0 {
+		root.Level = "all"
+	}
+	root.Appenders = []string{"test_buffer"}
+	err := l4.Run(&Log4Config{Root: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l4.Close(true) })
+	return l4, buf
+}
+
+// output flushes l4 and returns the lines written so far.
+func output(l4 *Log4, buf *syncBuffer) []string {
+	l4.FlushSync(time.Second)
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
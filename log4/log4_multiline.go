@@ -0,0 +1,223 @@
+package log4
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/yefy/log4go/ee"
+)
+
+const MultilineKeep = "keep"
+const MultilineReplace = "replace"
+const MultilineEscape = "escape"
+const MultilineIndent = "indent"
+
+// defaultIndent prefixes the continuation lines of the indent policy.
+const defaultIndent = "\t"
+
+// Log4Multiline is how a pattern layout writes the newlines of a record,
+// including the %E error chain and stack, which indent writes as continuation
+// lines prefixed by Marker:
+//
+//	keep    - newlines are written as-is
+//	replace - every newline is replaced by Marker, <<EOL>> by default
+//	escape  - newlines are written as \n, \r as \r and backslashes as \\
+//	indent  - continuation lines are prefixed by Marker, a tab by default
+//
+// json layouts always escape newlines and ignore it.
+type Log4Multiline struct {
+	Policy string
+	Marker string
+}
+
+// ParseMultiline maps the multiline and multiline_marker yaml fields to a
+// policy, true is keep and false is replace. An empty policy stays empty so
+// that an appender without one uses the policy of the logger.
+func ParseMultiline(policy string, marker string) (Log4Multiline, error) {
+	switch strings.ToLower(policy) {
+	case "":
+		return Log4Multiline{}, nil
+	case "true", MultilineKeep:
+		return Log4Multiline{Policy: MultilineKeep}, nil
+	case "false", MultilineReplace:
+		if len(marker) <= 0 {
+			marker = endOfLine
+		}
+		return Log4Multiline{Policy: MultilineReplace, Marker: marker}, nil
+	case MultilineEscape:
+		return Log4Multiline{Policy: MultilineEscape}, nil
+	case MultilineIndent:
+		if len(marker) <= 0 {
+			marker = defaultIndent
+		}
+		return Log4Multiline{Policy: MultilineIndent, Marker: marker}, nil
+	}
+	return Log4Multiline{}, ee.New(nil, "not find multiline:%v, use:%+v|%+v|%+v|%+v", policy, MultilineKeep, MultilineReplace, MultilineEscape, MultilineIndent)
+}
+
+// ParseMultilineDef falls back to replace, Log4Config.Check rejects bad
+// policies before they get here.
+func ParseMultilineDef(policy string, marker string) Log4Multiline {
+	multiline, err := ParseMultiline(policy, marker)
+	if err != nil {
+		log4Warn("multiline falls back to %v, err:%v", MultilineReplace, err)
+		return Log4Multiline{Policy: MultilineReplace, Marker: endOfLine}
+	}
+	return multiline
+}
+
+// continuation prefixes the lines of the %E error chain and stack.
+func (multiline Log4Multiline) continuation() string {
+	if multiline.Policy == MultilineIndent {
+		return multiline.Marker
+	}
+	return ""
+}
+
+// apply rewrites the record text in dst[start:] in place.
+func (multiline Log4Multiline) apply(dst []byte, start int) []byte {
+	text := dst[start:]
+	switch multiline.Policy {
+	case MultilineKeep, "":
+		return dst
+	case MultilineEscape:
+		if bytes.IndexAny(text, "\r\n\\") < 0 {
+			return dst
+		}
+	default:
+		if bytes.IndexByte(text, '\n') < 0 {
+			return dst
+		}
+	}
+
+	src := string(text)
+	dst = dst[:start]
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch multiline.Policy {
+		case MultilineEscape:
+			switch c {
+			case '\\':
+				dst = append(dst, `\\`...)
+			case '\n':
+				dst = append(dst, `\n`...)
+			case '\r':
+				dst = append(dst, `\r`...)
+			default:
+				dst = append(dst, c)
+			}
+			continue
+		case MultilineReplace, MultilineIndent:
+			if c == '\r' && i+1 < len(src) && src[i+1] == '\n' {
+				continue
+			}
+			if c != '\n' {
+				dst = append(dst, c)
+				continue
+			}
+			if multiline.Policy == MultilineIndent {
+				dst = append(dst, '\n')
+			}
+			dst = append(dst, multiline.Marker...)
+		}
+	}
+	return dst
+}
+
+// Decode turns the text of one record written with the policy back into its
+// original text, for indent the continuation lines must already be joined, see
+// MultilineScanner. Text written with keep, or containing the replace marker
+// itself, cannot be told apart and is returned as-is.
+func (multiline Log4Multiline) Decode(text string) string {
+	switch multiline.Policy {
+	case MultilineReplace:
+		return strings.ReplaceAll(text, multiline.Marker, "\n")
+	case MultilineIndent:
+		return strings.ReplaceAll(text, "\n"+multiline.Marker, "\n")
+	case MultilineEscape:
+		if strings.IndexByte(text, '\\') < 0 {
+			return text
+		}
+		buf := make([]byte, 0, len(text))
+		for i := 0; i < len(text); i++ {
+			c := text[i]
+			if c == '\\' && i+1 < len(text) {
+				switch text[i+1] {
+				case '\\':
+					c = '\\'
+				case 'n':
+					c = '\n'
+				case 'r':
+					c = '\r'
+				default:
+					buf = append(buf, c)
+					continue
+				}
+				i++
+			}
+			buf = append(buf, c)
+		}
+		return string(buf)
+	}
+	return text
+}
+
+// MultilineScanner reads the records of a log written with a policy and
+// returns them decoded, for indent a record ends before the next line that
+// does not start with the marker. It is used like bufio.Scanner.
+type MultilineScanner struct {
+	multiline Log4Multiline
+	scanner   *bufio.Scanner
+	text      string
+	next      string
+	hasNext   bool
+}
+
+func NewMultilineScanner(r io.Reader, multiline Log4Multiline) *MultilineScanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return &MultilineScanner{multiline: multiline, scanner: scanner}
+}
+
+func (s *MultilineScanner) Scan() bool {
+	var line string
+	if s.hasNext {
+		line = s.next
+		s.hasNext = false
+	} else if s.scanner.Scan() {
+		line = s.scanner.Text()
+	} else {
+		return false
+	}
+
+	if s.multiline.Policy == MultilineIndent {
+		var sb strings.Builder
+		sb.WriteString(line)
+		for s.scanner.Scan() {
+			next := s.scanner.Text()
+			if !strings.HasPrefix(next, s.multiline.Marker) {
+				s.next = next
+				s.hasNext = true
+				break
+			}
+			sb.WriteByte('\n')
+			sb.WriteString(next[len(s.multiline.Marker):])
+		}
+		s.text = sb.String()
+		return true
+	}
+
+	s.text = s.multiline.Decode(line)
+	return true
+}
+
+// Text returns the decoded record of the last Scan.
+func (s *MultilineScanner) Text() string {
+	return s.text
+}
+
+func (s *MultilineScanner) Err() error {
+	return s.scanner.Err()
+}
@@ -0,0 +1,83 @@
+package log4
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yefy/log4go/ee"
+)
+
+const testStack = "goroutine 1 [running]:\nmain.work()\n\t/src/main.go:12 +0x1d\nmain.main()\n\t/src/main.go:5 +0x17\n"
+
+func newMultilineRecord() *Log4Record {
+	rec := newBenchRecord()
+	rec.Level = "ERROR"
+	rec.Message = "first\nsecond"
+	rec.ErrFrames = []ee.Frame{
+		{Source: "a/b/c.go:10@load", Message: "load"},
+		{Message: "eof"},
+	}
+	rec.Stack = testStack
+	return rec
+}
+
+// TestMultilineException checks that the error chain and the stack follow
+// the policy like the message: one record per line for replace and escape,
+// continuation lines for indent, and that every record decodes back.
+func TestMultilineException(t *testing.T) {
+	stackLines := strings.Split(strings.TrimSuffix(testStack, "\n"), "\n")
+	keepLines := 2 + 2 + len(stackLines)
+	tests := []struct {
+		policy string
+		lines  int
+	}{
+		{MultilineKeep, keepLines},
+		{MultilineReplace, 1},
+		{MultilineEscape, 1},
+		{MultilineIndent, keepLines},
+	}
+	for _, pattern := range []string{"[%L] %M", "[%L] %M%E"} {
+		rec := newMultilineRecord()
+		want := strings.TrimSuffix(string(CompileLayout(pattern).appendFormat(nil, rec, nil, false, ParseMultilineDef(MultilineKeep, ""))), "\n")
+		rec.Put()
+		wantLines := append([]string{"[ERROR] first", "second", "\t[a/b/c.go:10@load emsg(load)]", "\teof"}, stackLines...)
+		if want != strings.Join(wantLines, "\n") {
+			t.Fatalf("%v keep:\n%s", pattern, want)
+		}
+
+		for _, test := range tests {
+			t.Run(pattern+"/"+test.policy, func(t *testing.T) {
+				rec := newMultilineRecord()
+				defer rec.Put()
+				multiline := ParseMultilineDef(test.policy, "")
+				out := string(CompileLayout(pattern).appendFormat(nil, rec, nil, false, multiline))
+				lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+				if len(lines) != test.lines {
+					t.Fatalf("%v lines, want %v:\n%s", len(lines), test.lines, out)
+				}
+				if test.policy == MultilineKeep {
+					return
+				}
+				if test.policy == MultilineIndent {
+					for i, line := range lines[1:] {
+						if line != "\t"+wantLines[i+1] {
+							t.Fatalf("line %q, want %q in:\n%s", line, "\t"+wantLines[i+1], out)
+						}
+					}
+				}
+
+				scanner := NewMultilineScanner(strings.NewReader(out+out), multiline)
+				count := 0
+				for scanner.Scan() {
+					count++
+					if scanner.Text() != want {
+						t.Fatalf("scanned record:\n%s\nwant:\n%s", scanner.Text(), want)
+					}
+				}
+				if count != 2 {
+					t.Fatalf("scanned %v records, want 2", count)
+				}
+			})
+		}
+	}
+}
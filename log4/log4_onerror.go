@@ -0,0 +1,213 @@
+package log4
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/yefy/log4go/ee"
+)
+
+// on_error policies of an appender, applied to the bytes a write could not
+// get out:
+//
+//	retry    - write them again retry_count times, waiting retry_backoff then
+//	           twice as long each time, then drop them (default). While the
+//	           appender keeps failing they are dropped without a retry, the
+//	           next write probes it, so the queue is not held up.
+//	fallback - queue them to the fallback appender as they are, a raw record
+//	           of the level of each record they belong to
+//	callback - hand them to the handler registered as error_handler
+//	drop     - drop them
+//
+// The records of the bytes handed to the fallback or the callback count as
+// Forwarded, the others as Dropped.
+const OnErrorRetry = "retry"
+const OnErrorFallback = "fallback"
+const OnErrorCallback = "callback"
+const OnErrorDrop = "drop"
+
+const defaultRetryCount = 3
+const defaultRetryBackoff = 100 * time.Millisecond
+
+// WriteFailure is what an ErrorHandler gets, Data is its own copy.
+type WriteFailure struct {
+	Appender string
+	Data     []byte
+	Err      error
+}
+
+type ErrorHandler func(failure WriteFailure)
+
+var errorHandlerMap sync.Map
+
+// RegisterErrorHandler makes handler usable as error_handler by the appenders
+// with on_error: callback, register it before InitFile.
+func RegisterErrorHandler(name string, handler ErrorHandler) {
+	errorHandlerMap.Store(name, handler)
+}
+
+func getErrorHandler(name string) (ErrorHandler, bool) {
+	handlerI, ok := errorHandlerMap.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return handlerI.(ErrorHandler), true
+}
+
+// onErrorCheck validates the on_error fields of the appender, appenders are
+// the names a fallback may use.
+func (appender *Log4ConfigAppender) onErrorCheck(name string, appenders map[string]bool) error {
+	switch appender.OnError {
+	case "", OnErrorRetry, OnErrorDrop:
+	case OnErrorFallback:
+		if len(appender.Fallback) <= 0 {
+			return ee.New(nil, "fallback nil with on_error:%v", appender.OnError)
+		}
+		if appender.Fallback == name {
+			return ee.New(nil, "fallback:%v to itself", appender.Fallback)
+		}
+		if !appenders[appender.Fallback] {
+			return ee.New(nil, "not find fallback:%v", appender.Fallback)
+		}
+	case OnErrorCallback:
+		_, ok := getErrorHandler(appender.ErrorHandler)
+		if !ok {
+			return ee.New(nil, "not find error_handler:%v, use log4.RegisterErrorHandler", appender.ErrorHandler)
+		}
+	default:
+		return ee.New(nil, "not find on_error:%v, use:%+v|%+v|%+v|%+v", appender.OnError, OnErrorRetry, OnErrorFallback, OnErrorCallback, OnErrorDrop)
+	}
+	if appender.RetryCount < 0 {
+		return ee.New(nil, "retry_count:%v < 0", appender.RetryCount)
+	}
+	if len(appender.RetryBackoff) > 0 {
+		_, err := time.ParseDuration(appender.RetryBackoff)
+		if err != nil {
+			return ee.New(err, "retry_backoff:%v", appender.RetryBackoff)
+		}
+	}
+	return nil
+}
+
+// onErrorPolicy is the resolved on_error of an appender.
+type onErrorPolicy struct {
+	policy       string
+	retryCount   int
+	retryBackoff time.Duration
+	fallback     recordLogger
+	handler      ErrorHandler
+}
+
+func newOnErrorPolicy(appender *Log4ConfigAppender) onErrorPolicy {
+	policy := onErrorPolicy{
+		policy:       appender.OnError,
+		retryCount:   appender.RetryCount,
+		retryBackoff: defaultRetryBackoff,
+	}
+	if len(policy.policy) <= 0 {
+		policy.policy = OnErrorRetry
+	}
+	if policy.retryCount <= 0 {
+		policy.retryCount = defaultRetryCount
+	}
+	if len(appender.RetryBackoff) > 0 {
+		backoff, err := time.ParseDuration(appender.RetryBackoff)
+		if err == nil {
+			policy.retryBackoff = backoff
+		}
+	}
+	if policy.policy == OnErrorCallback {
+		policy.handler, _ = getErrorHandler(appender.ErrorHandler)
+	}
+	return policy
+}
+
+// appenderContext is implemented by the appenders of this package, it gives
+// access to what Log4.Run resolves after creating them.
+type appenderContext interface {
+	appenderContext() *Log4AppenderContext
+}
+
+// writeResult applies the retry policy to the result of a write or flush of
+// the appender, it returns nil unless the bytes are lost, see settle for the
+// fallback and callback policies. Only the goroutine of the appender calls it.
+func (context *Log4AppenderContext) writeResult(err error) error {
+	if err == nil {
+		if context.failedAt.Swap(0) != 0 {
+			raiseStatus(StatusEvent{Kind: StatusWriteRecovered, Appender: context.name, Message: "writes recovered"})
+		}
+		return nil
+	}
+
+	context.stats.writeErrors.Add(1)
+	failing := context.failedAt.Swap(time.Now().UnixNano()) != 0
+	if !failing {
+		raiseStatus(StatusEvent{Kind: StatusWriteFailed, Appender: context.name, Message: "writes failing, on_error:" + context.onError.policy + ", err:" + causeOf(err), Err: err})
+	}
+	var writeErr *Log4WriteError
+	if context.onError.policy != OnErrorRetry || failing || !errors.As(err, &writeErr) || len(writeErr.Data) == 0 {
+		return err
+	}
+
+	backoff := context.onError.retryBackoff
+	for i := 0; i < context.onError.retryCount; i++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		retryErr := context.writer.FlushBuf(writeErr.Data)
+		if retryErr == nil {
+			return context.writeResult(nil)
+		}
+		context.stats.writeErrors.Add(1)
+		errors.As(retryErr, &writeErr)
+		err = retryErr
+	}
+	return err
+}
+
+// forwards reports whether the on_error policy hands lost bytes on.
+func (policy *onErrorPolicy) forwards() bool {
+	return (policy.policy == OnErrorFallback && policy.fallback != nil) || policy.policy == OnErrorCallback
+}
+
+// settle applies the on_error policy to the result of a write or flush, then
+// counts the records that left the buffer. A write kept in the buffer tells
+// nothing about the writer. Bytes handed to the fallback or the callback
+// return nil, their records count as Forwarded.
+func (context *Log4AppenderContext) settle(err error, buffered int) error {
+	if err == nil && buffered >= context.pending.size {
+		return nil
+	}
+	err = context.writeResult(err)
+	var writeErr *Log4WriteError
+	if err != nil && context.onError.forwards() && errors.As(err, &writeErr) && len(writeErr.Data) > 0 {
+		context.pending.forward(context, buffered, writeErr)
+		return nil
+	}
+	context.pending.settle(context.stats, buffered, err)
+	return err
+}
+
+// NewRawRecord returns a record written by the appenders as data, without
+// formatting, e.g. bytes another appender failed to write.
+func NewRawRecord(data []byte) *Log4Record {
+	rec := NewLog4Record()
+	*rec = Log4Record{Pool: rec.Pool}
+	rec.RefCount.Store(1)
+	rec.Created = time.Now()
+	rec.CreatedUtc = rec.Created.UTC()
+	rec.Raw = data
+	return rec
+}
+
+// forwardRaw queues data to the fallback as a raw record of the level and
+// creation time of the record it belongs to.
+func (context *Log4AppenderContext) forwardRaw(data []byte, write pendingWrite) {
+	rec := NewRawRecord(data)
+	rec.Level = write.level
+	if !write.created.IsZero() {
+		rec.Created = write.created
+		rec.CreatedUtc = write.created.UTC()
+	}
+	context.onError.fallback.LogRecord(rec)
+}
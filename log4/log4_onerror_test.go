@@ -0,0 +1,112 @@
+package log4
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/yefy/log4go/ee"
+)
+
+// newTestConsole returns a console appender writing into w that is not run,
+// the test drives it, with stats of its own.
+func newTestConsole(name string, w *shortWriter, appender *Log4ConfigAppender) *Log4ConsoleAppender {
+	appender.Kind = KindConsole
+	appender.Color = ConsoleColorNever
+	if len(appender.Pattern) <= 0 {
+		appender.Pattern = "[%L] %M"
+	}
+	console := NewLog4ConsoleAppender(name, appender)
+	console.writer = NewLog4WriterSize(w, 64)
+	console.Context.writer = console.writer
+	console.Context.stats = &appenderStats{}
+	return console
+}
+
+func newLevelRecord(level string, message string) *Log4Record {
+	rec := newBenchRecord()
+	rec.Level = level
+	rec.Message = message
+	return rec
+}
+
+func TestOnErrorFallback(t *testing.T) {
+	fallbackWriter := &shortWriter{limit: -1}
+	fallback := newTestConsole("test_fallback", fallbackWriter, &Log4ConfigAppender{})
+	// the first record gets out, the second one in part
+	w := &shortWriter{limit: 20}
+	primary := newTestConsole("test_primary", w, &Log4ConfigAppender{OnError: OnErrorFallback, Fallback: "test_fallback"})
+	primary.Context.onError.fallback = fallback
+	cache := formatCacheType{}
+
+	BufferWriteAndDropRec(primary, &primary.Context, newLevelRecord("INFO", "first"), &cache)
+	BufferWriteAndDropRec(primary, &primary.Context, newLevelRecord("ERROR", "second"), &cache)
+	BufferWriteAndDropRec(primary, &primary.Context, newLevelRecord("INFO", "third"), &cache)
+	if err := primary.BufferFlush(); err != nil {
+		t.Fatalf("BufferFlush = %v", err)
+	}
+	if stats := primary.Context.stats.snapshot(); stats.Written != 1 || stats.Forwarded != 2 || stats.Dropped != 0 ||
+		stats.Levels["INFO"] != 1 || stats.Bytes != 13 {
+		t.Fatalf("primary:%+v", stats)
+	}
+
+	BufferFlush(fallback, &fallback.Context, &cache)
+	if got := w.buf.String() + fallbackWriter.buf.String(); got != "[INFO] first\n[ERROR] second\n[INFO] third\n" {
+		t.Fatalf("written:%q", got)
+	}
+	if stats := fallback.Context.stats.snapshot(); stats.Written != 2 || stats.Levels["ERROR"] != 1 || stats.Levels["INFO"] != 1 {
+		t.Fatalf("fallback:%+v", stats)
+	}
+}
+
+func TestOnErrorCallback(t *testing.T) {
+	var failures []WriteFailure
+	RegisterErrorHandler("test_callback", func(failure WriteFailure) { failures = append(failures, failure) })
+	w := &shortWriter{limit: 0}
+	console := newTestConsole("test_callback", w, &Log4ConfigAppender{OnError: OnErrorCallback, ErrorHandler: "test_callback"})
+	cache := formatCacheType{}
+
+	BufferWriteAndDropRec(console, &console.Context, newLevelRecord("INFO", "first"), &cache)
+	BufferWriteAndDropRec(console, &console.Context, newLevelRecord("WARN", "second"), &cache)
+	console.BufferFlush()
+	if len(failures) != 1 || string(failures[0].Data) != "[INFO] first\n[WARN] second\n" || !errors.Is(failures[0].Err, errWriteFailed) {
+		t.Fatalf("failures:%+v", failures)
+	}
+	if stats := console.Context.stats.snapshot(); stats.Written != 0 || stats.Forwarded != 2 || stats.Dropped != 0 || stats.Bytes != 0 {
+		t.Fatalf("stats:%+v", stats)
+	}
+}
+
+func TestWriteFailedStatus(t *testing.T) {
+	var events []StatusEvent
+	SetStatusHandler(func(event StatusEvent) { events = append(events, event) })
+	defer SetStatusHandler(nil)
+
+	w := &shortWriter{limit: 0}
+	console := newTestConsole("test_status", w, &Log4ConfigAppender{OnError: OnErrorDrop})
+	BufferWriteAndDropRec(console, &console.Context, newBenchRecord(), &formatCacheType{})
+	console.BufferFlush()
+	w.limit = -1
+	BufferWriteAndDropRec(console, &console.Context, newBenchRecord(), &formatCacheType{})
+	console.BufferFlush()
+
+	if len(events) != 2 || events[0].Kind != StatusWriteFailed || events[1].Kind != StatusWriteRecovered {
+		t.Fatalf("events:%+v", events)
+	}
+	text := events[0].String()
+	if text != "appender:test_status writes failing, on_error:drop, err:write failed" {
+		t.Errorf("text:%q", text)
+	}
+	var writeErr *Log4WriteError
+	if !errors.As(events[0].Err, &writeErr) || !errors.Is(events[0].Err, errWriteFailed) {
+		t.Errorf("err:%v", events[0].Err)
+	}
+
+	// the cause of a plain ee error is its message
+	if cause := causeOf(ee.New(nil, "open failed")); cause != "open failed" {
+		t.Errorf("cause:%q", cause)
+	}
+	if cause := causeOf(&os.PathError{Op: "write", Path: "/dev/full", Err: errors.New("no space left on device")}); cause != "write /dev/full: no space left on device" {
+		t.Errorf("cause:%q", cause)
+	}
+}
@@ -0,0 +1,148 @@
+package log4
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yefy/log4go/ee"
+)
+
+// Parse reads one record written by the layout back, the text must be
+// decoded first, see Log4Multiline.Decode. The codes set Created, Level,
+// Target, Source, Func, Message, GoroutineId and Seq, the others are only
+// matched. Text after the last code, such as the fields and the error chain
+// appended when the pattern has no %X or %E, is kept in Message, as is the
+// text of a code following %M with no literal between them.
+func (layout *Log4Layout) Parse(text string) (*Log4Record, error) {
+	if layout.isJson {
+		return parseJson(text)
+	}
+
+	rec := &Log4Record{}
+	var dateLayout, dateValue, timeLayout, timeValue string
+	pos := 0
+	for i := range layout.tokens {
+		token := &layout.tokens[i]
+		if token.code == 0 {
+			if !strings.HasPrefix(text[pos:], token.literal) {
+				return nil, ee.New(nil, "text does not match %q at %v", token.literal, pos)
+			}
+			pos += len(token.literal)
+			continue
+		}
+
+		// codes with no literal between them, such as %M%E, leave the text up
+		// to the next literal to the first one
+		end := len(text)
+		for j := i + 1; j < len(layout.tokens); j++ {
+			next := layout.tokens[j]
+			if next.code != 0 {
+				continue
+			}
+			n := strings.Index(text[pos:], next.literal)
+			if n < 0 {
+				return nil, ee.New(nil, "text does not match %q after %%%c", next.literal, token.code)
+			}
+			end = pos + n
+			break
+		}
+		value := text[pos:end]
+		pos = end
+		if token.code != 'M' {
+			value = strings.TrimSpace(value)
+		}
+
+		switch token.code {
+		case 'D':
+			if len(token.timeLayout) > 0 {
+				dateLayout, dateValue = token.timeLayout, value
+			} else {
+				dateLayout, dateValue = "2006-01-02", value
+			}
+		case 'd':
+			dateLayout, dateValue = "02-01-06", value
+		case 'T':
+			timeLayout, timeValue = "15:04:05.000 MST", value
+		case 't':
+			timeLayout, timeValue = "15:04", value
+		case 'L':
+			rec.Level = value
+		case 'C':
+			rec.Target = value
+		case 'S':
+			rec.Source = value
+		case 'F':
+			rec.Func = value
+		case 'M':
+			rec.Message = value
+		case 'G':
+			rec.GoroutineId, _ = strconv.ParseInt(value, 10, 64)
+		case 'N':
+			rec.Seq, _ = strconv.ParseUint(value, 10, 64)
+		}
+	}
+	if pos < len(text) {
+		rec.Message += text[pos:]
+	}
+
+	if len(dateLayout) > 0 || len(timeLayout) > 0 {
+		loc := time.Local
+		if layout.IsUtc {
+			loc = time.UTC
+		}
+		created, err := time.ParseInLocation(strings.TrimSpace(dateLayout+" "+timeLayout), strings.TrimSpace(dateValue+" "+timeValue), loc)
+		if err != nil {
+			return nil, ee.New(err, "parse time")
+		}
+		rec.Created = created
+		rec.CreatedUtc = created.UTC()
+	}
+	return rec, nil
+}
+
+type jsonRecord struct {
+	Time    string                 `json:"time"`
+	Level   string                 `json:"level"`
+	Target  string                 `json:"target"`
+	Source  string                 `json:"source"`
+	Message string                 `json:"message"`
+	Seq     uint64                 `json:"seq"`
+	Fields  map[string]interface{} `json:"fields"`
+	Error   []ee.Frame             `json:"error"`
+	Stack   string                 `json:"stack"`
+}
+
+// parseJson reads a record of the json layout, fields are sorted by key.
+func parseJson(text string) (*Log4Record, error) {
+	var jsonRec jsonRecord
+	err := json.Unmarshal([]byte(text), &jsonRec)
+	if err != nil {
+		return nil, ee.New(err, "json.Unmarshal")
+	}
+	created, err := time.Parse(jsonTimeLayout, jsonRec.Time)
+	if err != nil {
+		return nil, ee.New(err, "parse time")
+	}
+
+	rec := &Log4Record{
+		Level:      jsonRec.Level,
+		Target:     jsonRec.Target,
+		Created:    created,
+		CreatedUtc: created.UTC(),
+		Source:     jsonRec.Source,
+		Message:    jsonRec.Message,
+		Seq:        jsonRec.Seq,
+		Stack:      jsonRec.Stack,
+		ErrFrames:  jsonRec.Error,
+	}
+	for key, value := range jsonRec.Fields {
+		rec.Fields = append(rec.Fields, ee.Attr{Key: key, Value: value})
+	}
+	sort.Slice(rec.Fields, func(i, j int) bool {
+		return rec.Fields[i].Key < rec.Fields[j].Key
+	})
+	return rec, nil
+}
@@ -0,0 +1,126 @@
+package log4
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yefy/log4go/ee"
+)
+
+func TestLayoutParseRoundTrip(t *testing.T) {
+	created := time.Date(2026, 10, 19, 13, 45, 12, 345000000, time.Local)
+	newRecord := func() *Log4Record {
+		rec := newBenchRecord()
+		rec.Created = created
+		rec.CreatedUtc = created.UTC()
+		rec.Target = "db"
+		rec.Level = "WARN"
+		rec.Source = "log4/db/query.go:42@Run"
+		rec.Func = "github.com/yefy/log4go/db.Run"
+		rec.Message = "slow query, took:2s"
+		rec.GoroutineId = 17
+		rec.Seq = 1234
+		return rec
+	}
+
+	tests := []struct {
+		pattern string
+		// check compares the fields the codes of pattern set
+		check func(rec *Log4Record, parsed *Log4Record) bool
+	}{
+		{"[%D %T] %M", func(rec, parsed *Log4Record) bool { return parsed.Created.Equal(rec.Created) }},
+		{"[%d %t] %M", func(rec, parsed *Log4Record) bool {
+			return parsed.Created.Equal(rec.Created.Truncate(time.Minute))
+		}},
+		{"[%D{2006-01-02T15:04:05.000}] %M", func(rec, parsed *Log4Record) bool { return parsed.Created.Equal(rec.Created) }},
+		{"[%L] %M", func(rec, parsed *Log4Record) bool { return parsed.Level == rec.Level }},
+		{"[%C] %M", func(rec, parsed *Log4Record) bool { return parsed.Target == rec.Target }},
+		{"(%S) %M", func(rec, parsed *Log4Record) bool { return parsed.Source == rec.Source }},
+		{"<%F> %M", func(rec, parsed *Log4Record) bool { return parsed.Func == rec.Func }},
+		{"g%G %M", func(rec, parsed *Log4Record) bool { return parsed.GoroutineId == rec.GoroutineId }},
+		{"#%N %M", func(rec, parsed *Log4Record) bool { return parsed.Seq == rec.Seq }},
+		{"%M", func(rec, parsed *Log4Record) bool { return parsed.Message == rec.Message }},
+		{"[%-7L] [%10C] %M", func(rec, parsed *Log4Record) bool {
+			return parsed.Level == rec.Level && parsed.Target == rec.Target
+		}},
+		{"[%7L|%-10C] %M", func(rec, parsed *Log4Record) bool {
+			return parsed.Level == rec.Level && parsed.Target == rec.Target
+		}},
+		{"(%.12S) %M", func(rec, parsed *Log4Record) bool {
+			return parsed.Source == rec.Source[len(rec.Source)-12:]
+		}},
+		{"[%U %D %T] [%P] [%H] %M", func(rec, parsed *Log4Record) bool {
+			return parsed.Created.Equal(rec.Created) && parsed.Message == rec.Message
+		}},
+		{"[%D %T] [%C] [%L] (%S) %M", func(rec, parsed *Log4Record) bool {
+			return parsed.Created.Equal(rec.Created) && parsed.Target == rec.Target &&
+				parsed.Level == rec.Level && parsed.Source == rec.Source && parsed.Message == rec.Message
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			rec := newRecord()
+			defer rec.Put()
+			layout := NewLog4Layout(test.pattern, Log4LayoutOptions{})
+			text := strings.TrimSuffix(string(layout.AppendFormat(nil, rec, nil)), "\n")
+			parsed, err := layout.Parse(text)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", text, err)
+			}
+			if !strings.HasSuffix(parsed.Message, rec.Message) {
+				t.Errorf("Parse(%q) message:%q", text, parsed.Message)
+			}
+			if !test.check(rec, parsed) {
+				t.Errorf("Parse(%q) = %+v", text, parsed)
+			}
+		})
+	}
+}
+
+func TestLayoutParseJsonRoundTrip(t *testing.T) {
+	rec := newBenchRecord()
+	defer rec.Put()
+	rec.Created = time.Date(2026, 10, 19, 13, 45, 12, 345000000, time.UTC)
+	rec.Message = "two\nlines"
+	layout := NewLog4Layout("", Log4LayoutOptions{Layout: LayoutJson})
+	text := strings.TrimSuffix(string(layout.AppendFormat(nil, rec, nil)), "\n")
+	parsed, err := layout.Parse(text)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", text, err)
+	}
+	if !parsed.Created.Equal(rec.Created) || parsed.Level != rec.Level || parsed.Target != rec.Target || parsed.Message != rec.Message {
+		t.Errorf("Parse(%q) = %+v", text, parsed)
+	}
+}
+
+func TestLayoutParseMismatch(t *testing.T) {
+	layout := NewLog4Layout("[%L] %M", Log4LayoutOptions{})
+	_, err := layout.Parse("WARN no brackets")
+	if err == nil {
+		t.Fatal("Parse accepted a line of another pattern")
+	}
+}
+
+func TestLayoutParseAdjacentCodes(t *testing.T) {
+	rec := newBenchRecord()
+	defer rec.Put()
+	rec.Level = "ERROR"
+	rec.Message = "load failed"
+	rec.Fields = []ee.Attr{{Key: "path", Value: "/tmp/x"}}
+	rec.ErrFrames = []ee.Frame{{Source: "a/b.go:1@load", Message: "load"}, {Message: "eof"}}
+
+	for _, pattern := range []string{"[%L] %M%E", "[%L] %M%X%E"} {
+		multiline := ParseMultilineDef(MultilineEscape, "")
+		layout := NewLog4Layout(pattern, Log4LayoutOptions{})
+		text := strings.TrimSuffix(string(layout.appendFormat(nil, rec, nil, false, multiline)), "\n")
+		parsed, err := layout.Parse(multiline.Decode(text))
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", text, err)
+		}
+		if parsed.Level != "ERROR" || !strings.HasPrefix(parsed.Message, "load failed") ||
+			!strings.Contains(parsed.Message, "\n\t[a/b.go:1@load emsg(load)]\n\teof") || !strings.Contains(parsed.Message, "path=/tmp/x") {
+			t.Errorf("Parse(%q) = %+v", text, parsed)
+		}
+	}
+}
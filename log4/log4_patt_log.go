@@ -5,8 +5,12 @@ package log4
 import (
 	"bytes"
 	"fmt"
-	"regexp"
+	"github.com/yefy/log4go/ee"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 const (
@@ -16,127 +20,442 @@ const (
 	FORMAT_TIME_UTC = "%U"
 )
 
-var timeRe = regexp.MustCompile("\\%D\\{(.*?)\\}")
+// Log4LayoutCache keeps the date and time strings of the last formatted
+// second, it belongs to a single goroutine.
+type Log4LayoutCache struct {
+	LastUpdateSeconds int64
+	shortTime         string
+	shortDate         string
+	longTimeSeconds   string
+	zone              string
+	longDate          string
+}
+
+type formatCacheType = Log4LayoutCache
+
+func (cache *Log4LayoutCache) update(created time.Time) {
+	secs := created.Unix()
+	if cache.LastUpdateSeconds == secs && len(cache.longDate) > 0 {
+		return
+	}
+	year, month, day := created.Date()
+	hour, minute, second := created.Clock()
+	zone, _ := created.Zone()
+
+	var buf [16]byte
+	cache.LastUpdateSeconds = secs
+	cache.shortTime = string(appendInt2(appendByte(appendInt2(buf[:0], hour), ':'), minute))
+	cache.shortDate = string(appendInt2(appendByte(appendInt2(appendByte(appendInt2(buf[:0], day), '-'), int(month)), '-'), year%100))
+	cache.longTimeSeconds = string(appendInt2(appendByte(appendInt2(appendByte(appendInt2(buf[:0], hour), ':'), minute), ':'), second))
+	cache.zone = zone
+	cache.longDate = string(appendInt2(appendByte(appendInt2(appendByte(strconv.AppendInt(buf[:0], int64(year), 10), '-'), int(month)), '-'), day))
+}
+
+type layoutToken struct {
+	// code is the format code, 0 for literal text
+	code    byte
+	literal string
+	// timeLayout is the Go layout of a %D{...} code
+	timeLayout string
+	mod        formatModifier
+}
+
+// Log4Layout is a pattern compiled once into a token program, it is immutable
+// and safe to share, the time cache passed to AppendFormat is not.
+type Log4Layout struct {
+	Pattern string
+	IsUtc   bool
+	Options Log4LayoutOptions
+	tokens  []layoutToken
+
+	sourcePathKeep int
+	sourceFullFunc bool
+	isJson         bool
+	hasException   bool
+	hasFields      bool
+}
+
+var layoutMap sync.Map
+
+// CompileLayout returns the compiled layout of pattern with default options,
+// layouts are cached by pattern.
+func CompileLayout(pattern string) *Log4Layout {
+	layoutI, ok := layoutMap.Load(pattern)
+	if ok {
+		return layoutI.(*Log4Layout)
+	}
+	layoutI, _ = layoutMap.LoadOrStore(pattern, NewLog4Layout(pattern, Log4LayoutOptions{}))
+	return layoutI.(*Log4Layout)
+}
+
+// NewLog4Layout compiles pattern, invalid options fall back to the defaults,
+// Log4Config.Check reports them.
+func NewLog4Layout(pattern string, options Log4LayoutOptions) *Log4Layout {
+	layout := &Log4Layout{Pattern: pattern, Options: options}
+	keep, err := options.sourcePathKeep()
+	if err != nil {
+		keep = defaultSourcePathKeep
+	}
+	layout.sourcePathKeep = keep
+	layout.sourceFullFunc = options.SourceFunc == SourceFuncFull
+	layout.isJson = options.Layout == LayoutJson
+	if layout.isJson {
+		return layout
+	}
+
+	literal := make([]byte, 0, len(pattern))
+	addLiteral := func() {
+		if len(literal) > 0 {
+			layout.tokens = append(layout.tokens, layoutToken{literal: string(literal)})
+			literal = literal[:0]
+		}
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' {
+			literal = append(literal, pattern[i])
+			continue
+		}
+		i++
+		if i < len(pattern) && pattern[i] == '%' {
+			literal = append(literal, '%')
+			continue
+		}
+		mod, n := parseFormatModifier(pattern[i:])
+		i += n
+		if i >= len(pattern) {
+			break
+		}
+		code := pattern[i]
+		switch code {
+		case 'U':
+			layout.IsUtc = true
+			for i+1 < len(pattern) && pattern[i+1] == ' ' {
+				i++
+			}
+		case 'D':
+			token := layoutToken{code: code, mod: mod}
+			if i+1 < len(pattern) && pattern[i+1] == '{' {
+				end := strings.IndexByte(pattern[i+1:], '}')
+				if end >= 0 {
+					token.timeLayout = pattern[i+2 : i+1+end]
+					i += 1 + end
+				}
+			}
+			addLiteral()
+			layout.tokens = append(layout.tokens, token)
+		case 'X':
+			layout.hasFields = true
+			addLiteral()
+			layout.tokens = append(layout.tokens, layoutToken{code: code, mod: mod})
+		case 'E':
+			layout.hasException = true
+			addLiteral()
+			layout.tokens = append(layout.tokens, layoutToken{code: code, mod: mod})
+		case 'T', 't', 'd', 'L', 'S', 's', 'M', 'C', 'G', 'P', 'H', 'F', 'N', 'r':
+			addLiteral()
+			layout.tokens = append(layout.tokens, layoutToken{code: code, mod: mod})
+		}
+	}
+	addLiteral()
+	return layout
+}
+
+// UsesCode reports whether the layout contains the format code, json layouts
+// use the codes of the fields they write.
+func (layout *Log4Layout) UsesCode(code byte) bool {
+	if layout.isJson {
+		return strings.IndexByte(jsonLayoutCodes, code) >= 0
+	}
+	for i := range layout.tokens {
+		if layout.tokens[i].code == code {
+			return true
+		}
+	}
+	return false
+}
 
-type formatCacheType struct {
-	LastUpdateSeconds    int64
-	shortTime, shortDate string
-	longTime, longDate   string
+// AppendFormat appends the formatted record and a newline to dst, an empty
+// pattern formats nothing. cache may be nil.
+func (layout *Log4Layout) AppendFormat(dst []byte, rec *Log4Record, cache *Log4LayoutCache) []byte {
+	return layout.appendFormat(dst, rec, cache, layout.IsUtc, rec.Multiline)
+}
+
+func (layout *Log4Layout) appendFormat(dst []byte, rec *Log4Record, cache *Log4LayoutCache, isUtc bool, multiline Log4Multiline) []byte {
+	if rec == nil {
+		return dst
+	}
+	if layout.isJson {
+		return layout.appendJson(dst, rec)
+	}
+	if len(layout.tokens) == 0 {
+		return dst
+	}
+	if cache == nil {
+		cache = &Log4LayoutCache{}
+	}
+	created := rec.GetCreateTime(isUtc)
+	cache.update(created)
+	// the policy applies to the text from spanStart, %E applies it itself
+	spanStart := len(dst)
+
+	for i := range layout.tokens {
+		token := &layout.tokens[i]
+		start := len(dst)
+		switch token.code {
+		case 0:
+			dst = append(dst, token.literal...)
+			continue
+		case 'T':
+			dst = append(dst, cache.longTimeSeconds...)
+			dst = append(dst, '.')
+			dst = appendInt3(dst, created.Nanosecond()/1000000)
+			dst = append(dst, ' ')
+			dst = append(dst, cache.zone...)
+		case 't':
+			dst = append(dst, cache.shortTime...)
+		case 'D':
+			if len(token.timeLayout) > 0 {
+				dst = created.AppendFormat(dst, token.timeLayout)
+			} else {
+				dst = append(dst, cache.longDate...)
+			}
+		case 'd':
+			dst = append(dst, cache.shortDate...)
+		case 'L':
+			dst = append(dst, rec.Level...)
+		case 'S':
+			if layout.sourcePathKeep == defaultSourcePathKeep && !layout.sourceFullFunc {
+				dst = append(dst, rec.Source...)
+			} else {
+				dst = layout.appendSource(dst, rec, layout.sourcePathKeep)
+			}
+		case 's':
+			if len(rec.File) == 0 {
+				dst = append(dst, rec.Source[strings.LastIndexByte(rec.Source, '/')+1:]...)
+			} else {
+				dst = layout.appendSource(dst, rec, 1)
+			}
+		case 'M':
+			dst = append(dst, rec.Message...)
+		case 'C':
+			dst = append(dst, rec.Target...)
+		case 'G':
+			dst = strconv.AppendInt(dst, rec.GoroutineId, 10)
+		case 'P':
+			dst = append(dst, pidStr...)
+		case 'H':
+			dst = append(dst, hostname...)
+		case 'F':
+			dst = append(dst, rec.Func...)
+		case 'N':
+			dst = strconv.AppendUint(dst, rec.Seq, 10)
+		case 'r':
+			dst = strconv.AppendInt(dst, rec.Created.Sub(processStart).Milliseconds(), 10)
+		case 'X':
+			dst = appendFields(dst, rec.Fields)
+		case 'E':
+			dst = multiline.apply(dst, spanStart)
+			dst = appendException(dst, rec, multiline)
+			spanStart = len(dst)
+			continue
+		}
+		dst = token.mod.apply(dst, start)
+	}
+	dst = multiline.apply(dst, spanStart)
+	if !layout.hasFields && len(rec.Fields) > 0 {
+		dst = append(dst, ' ')
+		dst = appendFields(dst, rec.Fields)
+	}
+	if !layout.hasException {
+		dst = appendException(dst, rec, multiline)
+	}
+	dst = append(dst, '\n')
+	return dst
+}
+
+// appendFields appends the fields as key=value separated by spaces, values
+// with spaces or quotes are quoted.
+func appendFields(dst []byte, fields []ee.Attr) []byte {
+	for i, field := range fields {
+		if i > 0 {
+			dst = append(dst, ' ')
+		}
+		dst = append(dst, field.Key...)
+		dst = append(dst, '=')
+		start := len(dst)
+		dst = appendFieldValue(dst, field.Value)
+		if bytes.ContainsAny(dst[start:], " \"=\n") {
+			value := string(dst[start:])
+			dst = strconv.AppendQuote(dst[:start], value)
+		}
+	}
+	return dst
+}
+
+func appendFieldValue(dst []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return append(dst, v...)
+	case int:
+		return strconv.AppendInt(dst, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(dst, v, 10)
+	case uint64:
+		return strconv.AppendUint(dst, v, 10)
+	case bool:
+		return strconv.AppendBool(dst, v)
+	case error:
+		return append(dst, v.Error()...)
+	}
+	return fmt.Append(dst, value)
+}
+
+// appendException appends the error chain of the record, one tab indented
+// frame per line, then its stack on the following lines. For indent every
+// line starts with the marker, replace and escape keep the block on the line
+// of the record like the message.
+func appendException(dst []byte, rec *Log4Record, multiline Log4Multiline) []byte {
+	start := len(dst)
+	continuation := multiline.continuation()
+	indent := continuation + "\t"
+	for _, frame := range rec.ErrFrames {
+		dst = append(dst, '\n')
+		dst = append(dst, indent...)
+		dst = frame.AppendText(dst, indent)
+	}
+	stack := strings.TrimSuffix(rec.Stack, "\n")
+	for len(stack) > 0 {
+		line := stack
+		end := strings.IndexByte(stack, '\n')
+		if end >= 0 {
+			line, stack = stack[:end], stack[end+1:]
+		} else {
+			stack = ""
+		}
+		dst = append(dst, '\n')
+		dst = append(dst, continuation...)
+		dst = append(dst, line...)
+	}
+	if multiline.Policy == MultilineIndent {
+		return dst
+	}
+	return multiline.apply(dst, start)
 }
 
 // Known format codes:
 // %T - Time (15:04:05 MST)
 // %t - Time (15:04)
 // %D - Date (2006-01-02)
+// %D{2006-01-02T15:04:05} - Date and time in a Go layout
 // %d - Date (01-02-06)
 // %L - Level (FINE, DEBG, TRAC, WARN, ERROR, CRIT)
 // %S - Source
+// %s - Source file name and line
 // %M - Message
+// %C - Target
+// %G - Goroutine id
+// %P - Process id
+// %H - Hostname
+// %F - Function, fully qualified
+// %N - Sequence number, increasing per process
+// %r - Milliseconds since process start
+// %X - Fields as key=value, appended at the end of the line when the
+// pattern has no %X
+// %E - Error chain and stack of the record on the following lines, appended
+// at the end when the pattern has no %E
+// %% - A literal %
 // Ignores unknown formats
 // Recommended: "[%D %T] [%L] (%S) %M"
 // %U = utc
+//
+// Format modifiers (log4j style) go between % and the code:
+// %-5L - pad to 5 characters, left aligned
+// %20C - pad to 20 characters, right aligned
+// %.40S - keep at most the last 40 characters
+// %-10.20S - both
+//
+// Appenders compile their pattern once, FormatLogRecord is kept for callers
+// formatting a single record.
 func FormatLogRecord(format string, isUtc bool, rec *Log4Record, formatCache *formatCacheType) string {
-	if rec == nil {
-		return ""
-	}
-	if len(format) == 0 {
-		return ""
-	}
+	layout := CompileLayout(format)
+	return string(layout.appendFormat(make([]byte, 0, 128), rec, formatCache, isUtc || layout.IsUtc, rec.Multiline))
+}
 
-	out := bytes.NewBuffer(make([]byte, 0, 64))
-	Created := rec.GetCreateTime(isUtc)
-	secs := Created.UnixNano() / 1e9
+// PatternUsesCode reports whether pattern contains the format code, modifiers
+// included, e.g. 'G' for "%-6G".
+func PatternUsesCode(pattern string, code byte) bool {
+	return CompileLayout(pattern).UsesCode(code)
+}
 
-	cache := *formatCache
-	if cache.LastUpdateSeconds != secs {
-		month, day, year := Created.Month(), Created.Day(), Created.Year()
-		hour, minute, second, millisecond := Created.Hour(), Created.Minute(), Created.Second(), Created.Nanosecond()/1000000
+type formatModifier struct {
+	leftAlign bool
+	minWidth  int
+	maxWidth  int
+}
 
-		zone, _ := Created.Zone()
-		updated := &formatCacheType{
-			LastUpdateSeconds: secs,
-			shortTime:         fmt.Sprintf("%02d:%02d", hour, minute),
-			shortDate:         fmt.Sprintf("%02d-%02d-%02d", day, month, year%100),
-			longTime:          fmt.Sprintf("%02d:%02d:%02d.%03d %s", hour, minute, second, millisecond, zone),
-			longDate:          fmt.Sprintf("%04d-%02d-%02d", year, month, day),
+// parseFormatModifier parses the "-10.20" of "-10.20S..." and returns the
+// number of bytes it used.
+func parseFormatModifier(piece string) (formatModifier, int) {
+	mod := formatModifier{}
+	i := 0
+	if i < len(piece) && piece[i] == '-' {
+		mod.leftAlign = true
+		i++
+	}
+	for ; i < len(piece) && piece[i] >= '0' && piece[i] <= '9'; i++ {
+		mod.minWidth = mod.minWidth*10 + int(piece[i]-'0')
+	}
+	if i < len(piece) && piece[i] == '.' {
+		i++
+		for ; i < len(piece) && piece[i] >= '0' && piece[i] <= '9'; i++ {
+			mod.maxWidth = mod.maxWidth*10 + int(piece[i]-'0')
 		}
-		cache = *updated
-		formatCache = updated
-
-	}
-	//custom format datetime pattern %D{2006-01-02T15:04:05}
-	formatByte := changeDttmFormat(format, isUtc, rec)
-	// Split the string into pieces by % signs
-	pieces := bytes.Split(formatByte, []byte{'%'})
-
-	// Iterate over the pieces, replacing known formats
-	for i, piece := range pieces {
-		if i > 0 && len(piece) > 0 {
-			isFindUtc := false
-			switch piece[0] {
-			case 'T':
-				out.WriteString(cache.longTime)
-			case 't':
-				out.WriteString(cache.shortTime)
-			case 'D':
-				out.WriteString(cache.longDate)
-			case 'd':
-				out.WriteString(cache.shortDate)
-			case 'L':
-				out.WriteString(rec.Level)
-			case 'S':
-				out.WriteString(rec.Source)
-			case 'U':
-				isFindUtc = true
-			case 's':
-				slice := strings.Split(rec.Source, "/")
-				out.WriteString(slice[len(slice)-1])
-			case 'M':
-				out.WriteString(rec.Message)
-			case 'C':
-				out.WriteString(rec.Target)
-			}
-			if isFindUtc {
-				if len(piece) > 1 {
-					piece := piece[1:]
-					shipSpaceCount := 0
-					for i := 0; i < len(piece); i++ {
-						if piece[i] == ' ' {
-							shipSpaceCount += 1
-						} else {
-							break
-						}
-					}
-					out.Write(piece[shipSpaceCount:])
-				}
-			} else {
-				if len(piece) > 1 {
-					out.Write(piece[1:])
-				}
-			}
-		} else if len(piece) > 0 {
-			out.Write(piece)
+	}
+	return mod, i
+}
+
+// apply truncates the value written at dst[start:] from the left to maxWidth
+// and pads it to minWidth, in place. Widths count runes.
+func (mod formatModifier) apply(dst []byte, start int) []byte {
+	if mod.minWidth == 0 && mod.maxWidth == 0 {
+		return dst
+	}
+	count := utf8.RuneCount(dst[start:])
+	if mod.maxWidth > 0 && count > mod.maxWidth {
+		cut := start
+		for ; count > mod.maxWidth; count-- {
+			_, size := utf8.DecodeRune(dst[cut:])
+			cut += size
+		}
+		n := copy(dst[start:], dst[cut:])
+		dst = dst[:start+n]
+	}
+	pad := mod.minWidth - count
+	if pad <= 0 {
+		return dst
+	}
+	end := len(dst)
+	for i := 0; i < pad; i++ {
+		dst = append(dst, ' ')
+	}
+	if !mod.leftAlign {
+		copy(dst[start+pad:], dst[start:end])
+		for i := start; i < start+pad; i++ {
+			dst[i] = ' '
 		}
 	}
-	out.WriteByte('\n')
+	return dst
+}
 
-	return out.String()
+func appendByte(dst []byte, b byte) []byte {
+	return append(dst, b)
 }
 
-func changeDttmFormat(format string, isUtc bool, rec *Log4Record) []byte {
-	Created := rec.GetCreateTime(isUtc)
-	formatByte := []byte(format)
-	i := 0
-	formatByte = timeRe.ReplaceAllFunc(formatByte, func(s []byte) []byte {
-		if i < 2 {
-			i++
-			str := string(s)
-			str = strings.Replace(str, "%D", "", -1)
-			str = strings.Replace(str, "{", "", -1)
-			str = strings.Replace(str, "}", "", -1)
-			return []byte(Created.Format(str))
-		}
-		return s
-	})
-	return formatByte
+func appendInt2(dst []byte, v int) []byte {
+	return append(dst, byte('0'+v/10%10), byte('0'+v%10))
+}
+
+func appendInt3(dst []byte, v int) []byte {
+	return append(dst, byte('0'+v/100%10), byte('0'+v/10%10), byte('0'+v%10))
 }
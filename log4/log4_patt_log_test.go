@@ -0,0 +1,284 @@
+package log4
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yefy/log4go/ee"
+)
+
+var benchPatterns = []struct {
+	name    string
+	pattern string
+}{
+	{"default", "[%D %T] [%C] [%L] (%S) %M"},
+	{"custom_date", "[%D{2006-01-02T15:04:05}] [%C] [%L] (%S) %M"},
+}
+
+func newBenchRecord() *Log4Record {
+	rec := NewLog4Record()
+	rec.Target = "main"
+	rec.Level = "INFO"
+	rec.Source = "log4go/log4/log4_patt_log_test.go:33@newBenchRecord"
+	rec.Message = "i:12345"
+	// pooled records keep what an earlier test set
+	rec.Stack = ""
+	rec.ErrFrames = nil
+	rec.Fields = nil
+	rec.Raw = nil
+	rec.Multiline = Log4Multiline{}
+	return rec
+}
+
+func BenchmarkFormatLogRecord(b *testing.B) {
+	for _, bench := range benchPatterns {
+		b.Run(bench.name, func(b *testing.B) {
+			rec := newBenchRecord()
+			defer rec.Put()
+			cache := formatCacheType{}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				FormatLogRecord(bench.pattern, false, rec, &cache)
+			}
+		})
+	}
+}
+
+// BenchmarkReferenceFormatLogRecord measures the formatter the compiled
+// layout replaced, for comparison.
+func BenchmarkReferenceFormatLogRecord(b *testing.B) {
+	for _, bench := range benchPatterns {
+		b.Run(bench.name, func(b *testing.B) {
+			rec := newBenchRecord()
+			defer rec.Put()
+			cache := referenceCache{}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				referenceFormatLogRecord(bench.pattern, false, rec, &cache)
+			}
+		})
+	}
+}
+
+func BenchmarkLayoutAppendFormat(b *testing.B) {
+	for _, bench := range benchPatterns {
+		b.Run(bench.name, func(b *testing.B) {
+			rec := newBenchRecord()
+			defer rec.Put()
+			layout := CompileLayout(bench.pattern)
+			cache := Log4LayoutCache{}
+			buf := make([]byte, 0, 256)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf = layout.AppendFormat(buf[:0], rec, &cache)
+			}
+		})
+	}
+}
+
+func TestFormatModifiers(t *testing.T) {
+	tests := []struct {
+		pattern string
+		message string
+		want    string
+	}{
+		{"[%-5L]", "", "[INFO ]"},
+		{"[%5L]", "", "[ INFO]"},
+		{"[%2L]", "", "[INFO]"},
+		{"[%.3C]", "", "[ain]"},
+		{"[%-8.3C]", "", "[ain     ]"},
+		{"[%8.3C]", "", "[     ain]"},
+		// widths count runes, truncation keeps the end
+		{"[%.10M]", "héllo wörld!", "[llo wörld!]"},
+		{"[%-6M]", "ö", "[ö     ]"},
+		{"%%[%3L]%%", "", "%[INFO]%"},
+		{"[%.0M]", "kept", "[kept]"},
+	}
+	for _, test := range tests {
+		rec := newBenchRecord()
+		if len(test.message) > 0 {
+			rec.Message = test.message
+		}
+		got := string(CompileLayout(test.pattern).AppendFormat(nil, rec, nil))
+		rec.Put()
+		if got != test.want+"\n" {
+			t.Errorf("%q: %q, want %q", test.pattern, got, test.want)
+		}
+	}
+
+	mod, n := parseFormatModifier("-10.20S rest")
+	if mod != (formatModifier{leftAlign: true, minWidth: 10, maxWidth: 20}) || n != 6 {
+		t.Errorf("parseFormatModifier = %+v, %v", mod, n)
+	}
+	if !PatternUsesCode("[%-6.2G]", 'G') || PatternUsesCode("[%-6L]", 'G') {
+		t.Error("PatternUsesCode with modifiers")
+	}
+}
+
+// TestLayoutMatchesReference checks that the compiled layout formats the codes
+// the reference formatter knows the same way.
+func TestLayoutMatchesReference(t *testing.T) {
+	patterns := []string{
+		FORMAT_DEFAULT,
+		FORMAT_SHORT,
+		FORMAT_ABBREV,
+		"%U [%D %T] [%C] [%L] (%S) %M",
+		"[%D{2006-01-02T15:04:05.000}] (%s) %M",
+		"%M",
+		"no codes",
+	}
+	created := time.Date(2026, 10, 19, 9, 5, 7, 8000000, time.Local)
+	for _, pattern := range patterns {
+		rec := newBenchRecord()
+		rec.Created = created
+		rec.CreatedUtc = created.UTC()
+		want := referenceFormatLogRecord(pattern, false, rec, &referenceCache{})
+		got := string(CompileLayout(pattern).AppendFormat(nil, rec, nil))
+		rec.Put()
+		if got != want {
+			t.Errorf("%q: %q, reference %q", pattern, got, want)
+		}
+	}
+}
+
+func TestFieldsAndExceptionCodes(t *testing.T) {
+	rec := newBenchRecord()
+	defer rec.Put()
+	rec.Fields = []ee.Attr{{Key: "user", Value: "ann lee"}, {Key: "n", Value: 3}}
+	rec.ErrFrames = []ee.Frame{{Source: "a/b.go:1@f", Message: "read"}, {Message: "eof"}}
+
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"%M", "i:12345 user=\"ann lee\" n=3\n\t[a/b.go:1@f emsg(read)]\n\teof\n"},
+		{"{%X} %M", "{user=\"ann lee\" n=3} i:12345\n\t[a/b.go:1@f emsg(read)]\n\teof\n"},
+		{"%M%E |", "i:12345\n\t[a/b.go:1@f emsg(read)]\n\teof | user=\"ann lee\" n=3\n"},
+	}
+	for _, test := range tests {
+		got := string(CompileLayout(test.pattern).AppendFormat(nil, rec, nil))
+		if got != test.want {
+			t.Errorf("%q: %q, want %q", test.pattern, got, test.want)
+		}
+	}
+}
+
+// referenceCache and referenceFormatLogRecord are the formatter before the
+// compiled layout, kept as the reference of its output and speed.
+type referenceCache struct {
+	LastUpdateSeconds    int64
+	shortTime, shortDate string
+	longTime, longDate   string
+}
+
+var referenceTimeRe = regexp.MustCompile("\\%D\\{(.*?)\\}")
+
+func referenceFormatLogRecord(format string, isUtc bool, rec *Log4Record, formatCache *referenceCache) string {
+	if rec == nil {
+		return ""
+	}
+	if len(format) == 0 {
+		return ""
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, 64))
+	Created := rec.GetCreateTime(isUtc)
+	secs := Created.UnixNano() / 1e9
+
+	cache := *formatCache
+	if cache.LastUpdateSeconds != secs {
+		month, day, year := Created.Month(), Created.Day(), Created.Year()
+		hour, minute, second, millisecond := Created.Hour(), Created.Minute(), Created.Second(), Created.Nanosecond()/1000000
+
+		zone, _ := Created.Zone()
+		updated := &referenceCache{
+			LastUpdateSeconds: secs,
+			shortTime:         fmt.Sprintf("%02d:%02d", hour, minute),
+			shortDate:         fmt.Sprintf("%02d-%02d-%02d", day, month, year%100),
+			longTime:          fmt.Sprintf("%02d:%02d:%02d.%03d %s", hour, minute, second, millisecond, zone),
+			longDate:          fmt.Sprintf("%04d-%02d-%02d", year, month, day),
+		}
+		cache = *updated
+		formatCache = updated
+
+	}
+	//custom format datetime pattern %D{2006-01-02T15:04:05}
+	formatByte := referenceChangeDttmFormat(format, isUtc, rec)
+	// Split the string into pieces by % signs
+	pieces := bytes.Split(formatByte, []byte{'%'})
+
+	// Iterate over the pieces, replacing known formats
+	for i, piece := range pieces {
+		if i > 0 && len(piece) > 0 {
+			isFindUtc := false
+			switch piece[0] {
+			case 'T':
+				out.WriteString(cache.longTime)
+			case 't':
+				out.WriteString(cache.shortTime)
+			case 'D':
+				out.WriteString(cache.longDate)
+			case 'd':
+				out.WriteString(cache.shortDate)
+			case 'L':
+				out.WriteString(rec.Level)
+			case 'S':
+				out.WriteString(rec.Source)
+			case 'U':
+				isFindUtc = true
+			case 's':
+				slice := strings.Split(rec.Source, "/")
+				out.WriteString(slice[len(slice)-1])
+			case 'M':
+				out.WriteString(rec.Message)
+			case 'C':
+				out.WriteString(rec.Target)
+			}
+			if isFindUtc {
+				if len(piece) > 1 {
+					piece := piece[1:]
+					shipSpaceCount := 0
+					for i := 0; i < len(piece); i++ {
+						if piece[i] == ' ' {
+							shipSpaceCount += 1
+						} else {
+							break
+						}
+					}
+					out.Write(piece[shipSpaceCount:])
+				}
+			} else {
+				if len(piece) > 1 {
+					out.Write(piece[1:])
+				}
+			}
+		} else if len(piece) > 0 {
+			out.Write(piece)
+		}
+	}
+	out.WriteByte('\n')
+
+	return out.String()
+}
+
+func referenceChangeDttmFormat(format string, isUtc bool, rec *Log4Record) []byte {
+	Created := rec.GetCreateTime(isUtc)
+	formatByte := []byte(format)
+	i := 0
+	formatByte = referenceTimeRe.ReplaceAllFunc(formatByte, func(s []byte) []byte {
+		if i < 2 {
+			i++
+			str := string(s)
+			str = strings.Replace(str, "%D", "", -1)
+			str = strings.Replace(str, "{", "", -1)
+			str = strings.Replace(str, "}", "", -1)
+			return []byte(Created.Format(str))
+		}
+		return s
+	})
+	return formatByte
+}
@@ -0,0 +1,108 @@
+package log4
+
+import (
+	"runtime"
+	"strings"
+)
+
+type recoverConfig struct {
+	target  *Log4Target
+	repanic bool
+}
+
+type RecoverOption func(config *recoverConfig)
+
+// WithRepanic panics again with the recovered value once it is logged.
+func WithRepanic() RecoverOption {
+	return func(config *recoverConfig) {
+		config.repanic = true
+	}
+}
+
+// WithTarget logs the panic through target instead of root.
+func WithTarget(target *Log4Target) RecoverOption {
+	return func(config *recoverConfig) {
+		config.target = target
+	}
+}
+
+// Recover logs a panic with its stack at CRITICAL through target, root when
+// nil, and flushes every appender. A WithTarget option wins over target. It
+// must be deferred directly:
+//
+//	defer log4.Recover(target)
+func Recover(target *Log4Target, opts ...RecoverOption) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if target != nil {
+		opts = append([]RecoverOption{WithTarget(target)}, opts...)
+	}
+	handlePanic(r, opts)
+}
+
+// Go runs fn in a new goroutine whose panics are logged like Recover does,
+// by default the goroutine ends and the process goes on.
+func Go(fn func(), opts ...RecoverOption) {
+	go func() {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			handlePanic(r, opts)
+		}()
+		fn()
+	}()
+}
+
+func handlePanic(r interface{}, opts []RecoverOption) {
+	config := recoverConfig{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	target := config.target
+	if target == nil {
+		target = Target(defaultRootTarget)
+	}
+
+	if target.Enabled(CRITICAL) {
+		depth := panicDepth()
+		rec := target.GetRecord(depth+1-target.callerSkip, CRITICAL, "panic: %v", r)
+		rec.Stack = CallerStack(depth)
+		target.emit(rec)
+	}
+	target.flushOwner()
+
+	if config.repanic {
+		panic(r)
+	}
+}
+
+// panicDepth returns how many frames above the caller of panicDepth the
+// function that panicked sits: the first frame past runtime.gopanic outside
+// the runtime.
+func panicDepth() int {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	depth := 0
+	isPanicking := false
+	for {
+		frame, more := frames.Next()
+		if isPanicking && !strings.HasPrefix(frame.Function, "runtime.") {
+			return depth
+		}
+		if frame.Function == "runtime.gopanic" {
+			isPanicking = true
+		}
+		if !more {
+			break
+		}
+		depth++
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+package log4_test
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yefy/log4go/log4"
+	"github.com/yefy/log4go/log4test"
+)
+
+// boomLine is the line of the panic in boom.
+var boomLine int
+
+func boom() {
+	_, _, line, _ := runtime.Caller(0)
+	boomLine = line + 2
+	panic("boom")
+}
+
+func checkPanicRecord(t *testing.T, rec *log4test.Recorder, target string) {
+	t.Helper()
+	records := rec.Find(log4.CRITICAL, target, "panic: boom")
+	if len(records) != 1 {
+		t.Fatalf("records:%+v", rec.Records())
+	}
+	if want := fmt.Sprintf("log4_recover_test.go:%v@boom", boomLine); !strings.HasSuffix(records[0].Source, want) {
+		t.Errorf("source:%q, want %v", records[0].Source, want)
+	}
+	if !strings.HasPrefix(records[0].Stack, "github.com/yefy/log4go/log4_test.boom()") {
+		t.Errorf("stack:\n%v", records[0].Stack)
+	}
+}
+
+func TestRecoverSwallows(t *testing.T) {
+	rec := log4test.New(t)
+	func() {
+		defer log4.Recover(rec.Target(""))
+		boom()
+	}()
+	checkPanicRecord(t, rec, "root")
+}
+
+func TestRecoverRepanics(t *testing.T) {
+	rec := log4test.New(t)
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		defer log4.Recover(rec.Target(""), log4.WithRepanic())
+		boom()
+	}()
+	if recovered != "boom" {
+		t.Errorf("recovered %v", recovered)
+	}
+	checkPanicRecord(t, rec, "root")
+}
+
+func TestRecoverWithTarget(t *testing.T) {
+	rec := log4test.New(t, log4test.WithLoggers("db"))
+	func() {
+		defer log4.Recover(rec.Target(""), log4.WithTarget(rec.Target("db")))
+		boom()
+	}()
+	checkPanicRecord(t, rec, "db")
+}
+
+func TestGoRecovers(t *testing.T) {
+	rec := log4test.New(t)
+	log4.Go(boom, log4.WithTarget(rec.Target("")))
+	deadline := time.Now().Add(time.Second)
+	for len(rec.Records()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	checkPanicRecord(t, rec, "root")
+}
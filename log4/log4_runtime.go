@@ -0,0 +1,66 @@
+package log4
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var processStart = time.Now()
+
+var pidStr = strconv.Itoa(os.Getpid())
+
+var hostname = func() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "???"
+	}
+	return name
+}()
+
+var recordSeq atomic.Uint64
+
+var goroutinePrefix = []byte("goroutine ")
+
+// GoroutineId parses the id of the current goroutine out of its stack header
+// "goroutine 18 [running]:", it costs a runtime.Stack call so records only
+// carry it when a pattern asks for %G.
+func GoroutineId() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, goroutinePrefix)
+	i := bytes.IndexByte(b, ' ')
+	if i < 0 {
+		return 0
+	}
+	id, err := strconv.ParseInt(string(b[:i]), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// CallerStack formats the stack of the current goroutine like debug.Stack,
+// skip counts like runtime.Caller.
+func CallerStack(skip int) string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	buf := make([]byte, 0, 1024)
+	for {
+		frame, more := frames.Next()
+		buf = append(buf, frame.Function...)
+		buf = append(buf, "()\n\t"...)
+		buf = append(buf, frame.File...)
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(frame.Line), 10)
+		buf = append(buf, '\n')
+		if !more {
+			break
+		}
+	}
+	return string(buf)
+}
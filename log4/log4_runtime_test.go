@@ -0,0 +1,123 @@
+package log4
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRuntimeCodes(t *testing.T) {
+	l4, buf := runLog4(t, "g=%G p=%P h=%H f=%F n=%N r=%r", Log4ConfigLogger{})
+	target := l4.Target("root")
+	if !target.needGoroutineId || !target.needCaller {
+		t.Fatalf("needGoroutineId:%v needCaller:%v", target.needGoroutineId, target.needCaller)
+	}
+	target.Info("first")
+	target.Info("second")
+	lines := output(l4, buf)
+	if len(lines) != 2 {
+		t.Fatalf("lines:%q", lines)
+	}
+
+	values := make([]map[string]string, len(lines))
+	for i, line := range lines {
+		values[i] = map[string]string{}
+		for _, field := range strings.Fields(line) {
+			key, value, _ := strings.Cut(field, "=")
+			values[i][key] = value
+		}
+	}
+	hostname, _ := os.Hostname()
+	want := map[string]string{
+		"g": strconv.FormatInt(GoroutineId(), 10),
+		"p": strconv.Itoa(os.Getpid()),
+		"h": hostname,
+		"f": "github.com/yefy/log4go/log4.TestRuntimeCodes",
+	}
+	for key, value := range want {
+		if values[0][key] != value {
+			t.Errorf("%%%v = %q, want %q in %q", key, values[0][key], value, lines[0])
+		}
+	}
+	first, _ := strconv.ParseUint(values[0]["n"], 10, 64)
+	second, _ := strconv.ParseUint(values[1]["n"], 10, 64)
+	if first == 0 || second != first+1 {
+		t.Errorf("sequence %v then %v", first, second)
+	}
+	elapsed, err := strconv.ParseInt(values[0]["r"], 10, 64)
+	if err != nil || elapsed < 0 || elapsed > time.Since(processStart).Milliseconds() {
+		t.Errorf("%%r = %q", values[0]["r"])
+	}
+}
+
+func TestRuntimeCodesOnlyWhenUsed(t *testing.T) {
+	l4, buf := runLog4(t, "[%L] %M", Log4ConfigLogger{})
+	target := l4.Target("root")
+	if target.needGoroutineId || target.needCaller {
+		t.Fatalf("needGoroutineId:%v needCaller:%v", target.needGoroutineId, target.needCaller)
+	}
+	rec := target.GetRecord(1, INFO, "plain")
+	defer rec.Put()
+	if rec.GoroutineId != 0 || len(rec.Source) != 0 || len(rec.Func) != 0 {
+		t.Errorf("record:%+v", rec)
+	}
+	target.Info("plain")
+	if lines := output(l4, buf); len(lines) != 1 || lines[0] != "[INFO] plain" {
+		t.Errorf("lines:%q", lines)
+	}
+}
+
+func TestGoroutineId(t *testing.T) {
+	id := GoroutineId()
+	other := make(chan int64)
+	go func() { other <- GoroutineId() }()
+	otherId := <-other
+	if id <= 0 || otherId <= 0 || id == otherId {
+		t.Errorf("ids %v and %v", id, otherId)
+	}
+}
+
+func TestStacktraceLevel(t *testing.T) {
+	l4, buf := runLog4(t, "[%L] %M", Log4ConfigLogger{StacktraceLevel: "error", Multiline: true})
+	target := l4.Target("root")
+	target.Warn("no stack")
+	target.Error("with stack")
+
+	lines := output(l4, buf)
+	if len(lines) < 3 || lines[0] != "[WARN] no stack" || lines[1] != "[ERROR] with stack" {
+		t.Fatalf("lines:%q", lines)
+	}
+	// the stack starts at the caller of Error
+	if lines[2] != "github.com/yefy/log4go/log4.TestStacktraceLevel()" || !strings.Contains(lines[3], "log4_runtime_test.go:") {
+		t.Errorf("stack:%q", lines[2:])
+	}
+	for _, line := range lines[2:] {
+		if strings.Contains(line, "log4.(*Log4Target)") {
+			t.Errorf("stack has the frames of log4:%q", lines[2:])
+		}
+	}
+
+	rec := target.GetRecord(1, INFO, "below")
+	defer rec.Put()
+	if len(rec.Stack) != 0 {
+		t.Errorf("INFO record has a stack")
+	}
+
+	config := Log4Config{Root: Log4ConfigLogger{Level: "info", StacktraceLevel: "severe"}}
+	if config.Check() == nil {
+		t.Error("Check accepts stacktrace_level severe")
+	}
+}
+
+func TestCallerStack(t *testing.T) {
+	stack := CallerStack(0)
+	lines := strings.Split(stack, "\n")
+	if lines[0] != "github.com/yefy/log4go/log4.TestCallerStack()" || !strings.HasPrefix(lines[1], "\t") || !strings.Contains(lines[1], "log4_runtime_test.go:") {
+		t.Errorf("CallerStack:\n%v", stack)
+	}
+	if !strings.HasSuffix(stack, "\n") {
+		t.Errorf("CallerStack does not end with a newline")
+	}
+}
@@ -0,0 +1,143 @@
+package log4
+
+import (
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yefy/log4go/ee"
+)
+
+const SourcePathBase = "base"
+const SourcePathFull = "full"
+const SourcePathModule = "module"
+
+const SourceFuncShort = "short"
+const SourceFuncFull = "full"
+
+const defaultSourcePathKeep = 3
+
+// sourcePathModuleKeep marks module relative paths in Log4Layout.sourcePathKeep.
+const sourcePathModuleKeep = -1
+
+const LayoutPattern = "pattern"
+const LayoutJson = "json"
+
+type Log4LayoutOptions struct {
+	Layout     string
+	SourcePath string
+	SourceFunc string
+}
+
+func (options Log4LayoutOptions) Check() error {
+	if len(options.Layout) > 0 && options.Layout != LayoutPattern && options.Layout != LayoutJson {
+		return ee.New(nil, "not find layout:%v, use:%+v|%+v", options.Layout, LayoutPattern, LayoutJson)
+	}
+	_, err := options.sourcePathKeep()
+	if err != nil {
+		return err
+	}
+	if len(options.SourceFunc) > 0 && options.SourceFunc != SourceFuncShort && options.SourceFunc != SourceFuncFull {
+		return ee.New(nil, "not find source_func:%v, use:%+v|%+v", options.SourceFunc, SourceFuncShort, SourceFuncFull)
+	}
+	return nil
+}
+
+// sourcePathKeep returns the number of trailing path segments to keep, 0 for
+// the full path.
+func (options Log4LayoutOptions) sourcePathKeep() (int, error) {
+	switch options.SourcePath {
+	case "":
+		return defaultSourcePathKeep, nil
+	case SourcePathBase:
+		return 1, nil
+	case SourcePathFull:
+		return 0, nil
+	case SourcePathModule:
+		return sourcePathModuleKeep, nil
+	}
+	keep, err := strconv.Atoi(options.SourcePath)
+	if err != nil || keep <= 0 {
+		return 0, ee.New(err, "not find source_path:%v, use:%+v|%+v|%+v|<segments>", options.SourcePath, SourcePathBase, SourcePathFull, SourcePathModule)
+	}
+	return keep, nil
+}
+
+// appendSource appends file:line@func like Log4Record.Source but with the
+// path and function style of the layout.
+func (layout *Log4Layout) appendSource(dst []byte, rec *Log4Record, keep int) []byte {
+	if len(rec.File) == 0 {
+		return append(dst, rec.Source...)
+	}
+	switch keep {
+	case 0:
+		dst = append(dst, rec.File...)
+	case sourcePathModuleKeep:
+		dst = append(dst, ModuleRelativePath(rec.File)...)
+	default:
+		dst = append(dst, ee.TrimPathN(rec.File, keep)...)
+	}
+	dst = append(dst, ':')
+	dst = strconv.AppendInt(dst, int64(rec.Line), 10)
+	dst = append(dst, '@')
+	if layout.sourceFullFunc {
+		dst = append(dst, rec.Func...)
+	} else {
+		dst = append(dst, ee.GetLastStrPart(rec.Func, ".")...)
+	}
+	return dst
+}
+
+var mainModulePath = func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	return info.Main.Path
+}()
+
+// moduleRootMap caches the module root of a source directory, "" when none.
+var moduleRootMap sync.Map
+
+// ModuleRelativePath returns file relative to the root of its Go module, for
+// -trimpath builds that is the main module path prefix, otherwise the nearest
+// directory holding a go.mod. Files outside any module keep 3 segments.
+func ModuleRelativePath(file string) string {
+	file = filepath.ToSlash(file)
+	if len(mainModulePath) > 0 {
+		if strings.HasPrefix(file, mainModulePath+"/") {
+			return file[len(mainModulePath)+1:]
+		}
+		if i := strings.Index(file, "/"+mainModulePath+"/"); i >= 0 {
+			return file[i+len(mainModulePath)+2:]
+		}
+	}
+
+	dir := filepath.Dir(file)
+	rootI, ok := moduleRootMap.Load(dir)
+	if !ok {
+		rootI, _ = moduleRootMap.LoadOrStore(dir, findModuleRoot(dir))
+	}
+	root := rootI.(string)
+	if len(root) == 0 {
+		return ee.TrimPathN(file, defaultSourcePathKeep)
+	}
+	return strings.TrimPrefix(file[len(root):], "/")
+}
+
+func findModuleRoot(dir string) string {
+	for {
+		_, err := os.Stat(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			return filepath.ToSlash(dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,108 @@
+package log4
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSourceOptions(t *testing.T) {
+	rec := newBenchRecord()
+	defer rec.Put()
+	rec.File = "/home/dev/src/app/internal/db/query.go"
+	rec.Line = 42
+	rec.Func = "example.com/app/internal/db.(*Conn).Query"
+	// as GetRecord sets it, the default options write it as it is
+	rec.Source = "internal/db/query.go:42@Query"
+
+	tests := []struct {
+		options Log4LayoutOptions
+		want    string
+	}{
+		{Log4LayoutOptions{}, "internal/db/query.go:42@Query"},
+		{Log4LayoutOptions{SourcePath: SourcePathBase}, "query.go:42@Query"},
+		{Log4LayoutOptions{SourcePath: SourcePathFull}, "/home/dev/src/app/internal/db/query.go:42@Query"},
+		{Log4LayoutOptions{SourcePath: "2"}, "db/query.go:42@Query"},
+		{Log4LayoutOptions{SourceFunc: SourceFuncFull}, "internal/db/query.go:42@example.com/app/internal/db.(*Conn).Query"},
+	}
+	for _, test := range tests {
+		got := string(NewLog4Layout("%S", test.options).AppendFormat(nil, rec, nil))
+		if got != test.want+"\n" {
+			t.Errorf("%+v: %q, want %q", test.options, got, test.want)
+		}
+		if err := test.options.Check(); err != nil {
+			t.Errorf("%+v: %v", test.options, err)
+		}
+	}
+	if got := string(CompileLayout("%s").AppendFormat(nil, rec, nil)); got != "query.go:42@Query\n" {
+		t.Errorf("%%s: %q", got)
+	}
+
+	for _, options := range []Log4LayoutOptions{
+		{SourcePath: "0"},
+		{SourcePath: "parent"},
+		{SourceFunc: "long"},
+		{Layout: "xml"},
+	} {
+		if options.Check() == nil {
+			t.Errorf("Check accepts %+v", options)
+		}
+	}
+}
+
+func TestModuleRelativePath(t *testing.T) {
+	_, file, _, _ := runtime.Caller(0)
+	if got := ModuleRelativePath(file); got != "log4/log4_source_test.go" {
+		t.Errorf("ModuleRelativePath(%q) = %q", file, got)
+	}
+	if got := ModuleRelativePath("/no/module/here/main.go"); got != "module/here/main.go" {
+		t.Errorf("outside a module: %q", got)
+	}
+}
+
+// logVia is a wrapper reporting the source of its caller.
+func logVia(target *Log4Target, msg string) {
+	target.WithCallerSkip(1).Info(msg)
+}
+
+func TestCallerSkip(t *testing.T) {
+	l4, buf := runLog4(t, "%s %M", Log4ConfigLogger{})
+	target := l4.Target("root")
+	_, _, line, _ := runtime.Caller(0)
+	target.Info("direct")
+	logVia(target, "wrapped")
+
+	lines := output(l4, buf)
+	want := []string{
+		"log4_source_test.go:" + strconv.Itoa(line+1) + "@TestCallerSkip direct",
+		"log4_source_test.go:" + strconv.Itoa(line+2) + "@TestCallerSkip wrapped",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lines:%q, want %q", lines, want)
+	}
+}
+
+func TestNeedCallerFollowsLayouts(t *testing.T) {
+	l4 := NewLog4("")
+	err := l4.Run(&Log4Config{
+		Appenders: map[string]Log4ConfigAppender{
+			"plain":  {Kind: KindConsole, Pattern: "[%L] %M"},
+			"source": {Kind: KindConsole, Pattern: "(%S) %M"},
+		},
+		Root: Log4ConfigLogger{Level: "info", Appenders: []string{"source"}},
+		Loggers: map[string]Log4ConfigLogger{
+			"additive": {Level: "info", Additive: true, Appenders: []string{"plain"}},
+			"alone":    {Level: "info", Appenders: []string{"plain"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l4.Close(true)
+	for name, want := range map[string]bool{"root": true, "additive": true, "alone": false} {
+		if got := l4.Target(name).needCaller; got != want {
+			t.Errorf("%v needCaller:%v, want %v", name, got, want)
+		}
+	}
+}
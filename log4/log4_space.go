@@ -0,0 +1,193 @@
+package log4
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yefy/log4go/ee"
+	"github.com/yefy/log4go/efile"
+)
+
+// low_space modes of a file appender, applied while the free space of its
+// filesystem is below min_free_space:
+//
+//	warn - write only the records at or above WARN (default)
+//	stop - write nothing
+const LowSpaceWarn = "warn"
+const LowSpaceStop = "stop"
+
+const defaultSpaceCheckInterval = 10 * time.Second
+
+var freeSpace = efile.FreeSpace
+
+// parseByteSize parses a size such as 512, 64KB, 100MB or 1GB, units are
+// powers of 1024.
+func parseByteSize(text string) (uint64, error) {
+	text = strings.TrimSpace(text)
+	number := strings.TrimRight(strings.ToUpper(text), "KMGTIB")
+	unit := strings.TrimSuffix(strings.ToUpper(text[len(number):]), "B")
+	unit = strings.TrimSuffix(unit, "I")
+	shift := 0
+	if len(unit) > 0 {
+		shift = strings.Index("KMGT", unit) + 1
+		if len(unit) > 1 || shift == 0 {
+			return 0, ee.New(nil, "unit of size:%v, use:B|KB|MB|GB|TB", text)
+		}
+	}
+	size, err := strconv.ParseUint(strings.TrimSpace(number), 10, 64)
+	if err != nil {
+		return 0, ee.New(err, "size:%v", text)
+	}
+	return size << (10 * shift), nil
+}
+
+// parseMinFreeSpace parses min_free_space, a size or a percent of the
+// filesystem such as 5%.
+func parseMinFreeSpace(text string) (uint64, float64, error) {
+	percent, ok := strings.CutSuffix(strings.TrimSpace(text), "%")
+	if !ok {
+		size, err := parseByteSize(text)
+		return size, 0, err
+	}
+	ratio, err := strconv.ParseFloat(percent, 64)
+	if err != nil || ratio <= 0 || ratio >= 100 {
+		return 0, 0, ee.New(err, "min_free_space:%v, use a percent in (0, 100)", text)
+	}
+	return 0, ratio / 100, nil
+}
+
+// spaceCheck validates the free space fields of a file appender.
+func (appender *Log4ConfigAppender) spaceCheck() error {
+	if len(appender.MinFreeSpace) <= 0 {
+		if len(appender.LowSpace) > 0 || len(appender.SpaceCheckInterval) > 0 {
+			return ee.New(nil, "low_space or space_check_interval without min_free_space")
+		}
+		return nil
+	}
+	if appender.Kind != KindFile {
+		return ee.New(nil, "min_free_space with kind:%v, use:%v", appender.Kind, KindFile)
+	}
+	_, _, err := parseMinFreeSpace(appender.MinFreeSpace)
+	if err != nil {
+		return err
+	}
+	if len(appender.LowSpace) > 0 && appender.LowSpace != LowSpaceWarn && appender.LowSpace != LowSpaceStop {
+		return ee.New(nil, "not find low_space:%v, use:%+v|%+v", appender.LowSpace, LowSpaceWarn, LowSpaceStop)
+	}
+	if len(appender.SpaceCheckInterval) > 0 {
+		interval, err := time.ParseDuration(appender.SpaceCheckInterval)
+		if err != nil {
+			return ee.New(err, "space_check_interval:%v", appender.SpaceCheckInterval)
+		}
+		if interval <= 0 {
+			return ee.New(nil, "space_check_interval:%v <= 0", appender.SpaceCheckInterval)
+		}
+	}
+	return nil
+}
+
+// spaceGuard degrades a file appender while its filesystem is low on space.
+// Only the goroutine of the appender uses it.
+type spaceGuard struct {
+	path       string
+	minFree    uint64
+	minRatio   float64
+	mode       string
+	interval   time.Duration
+	lastCheck  time.Time
+	low        bool
+	lastErrMsg string
+}
+
+// newSpaceGuard returns nil when the appender sets no min_free_space.
+func newSpaceGuard(appender *Log4ConfigAppender) *spaceGuard {
+	if len(appender.MinFreeSpace) <= 0 {
+		return nil
+	}
+	guard := &spaceGuard{
+		path:     appender.Path,
+		mode:     appender.LowSpace,
+		interval: defaultSpaceCheckInterval,
+	}
+	guard.minFree, guard.minRatio, _ = parseMinFreeSpace(appender.MinFreeSpace)
+	if len(guard.mode) <= 0 {
+		guard.mode = LowSpaceWarn
+	}
+	if len(appender.SpaceCheckInterval) > 0 {
+		interval, err := time.ParseDuration(appender.SpaceCheckInterval)
+		if err == nil && interval > 0 {
+			guard.interval = interval
+		}
+	}
+	return guard
+}
+
+// check reads the free space once the interval has passed, or right away
+// with force, and raises a status event when the appender changes mode.
+func (guard *spaceGuard) check(name string, force bool) {
+	if guard == nil {
+		return
+	}
+	now := time.Now()
+	if !force && now.Sub(guard.lastCheck) < guard.interval {
+		return
+	}
+	guard.lastCheck = now
+
+	free, total, err := freeSpace(guard.path)
+	if err != nil {
+		// keep the mode, warn once per distinct failure
+		if err.Error() != guard.lastErrMsg {
+			guard.lastErrMsg = err.Error()
+			raiseStatus(StatusEvent{Kind: StatusWarn, Appender: name, Message: "free space unknown, err:" + causeOf(err), Err: err})
+		}
+		return
+	}
+	guard.lastErrMsg = ""
+
+	minFree := guard.minFree
+	if guard.minRatio > 0 {
+		minFree = uint64(float64(total) * guard.minRatio)
+	}
+	low := free < minFree
+	if low == guard.low {
+		return
+	}
+	guard.low = low
+	if low {
+		raiseStatus(StatusEvent{Kind: StatusLowSpace, Appender: name,
+			Message: fmt.Sprintf("free space %v below %v, low_space:%v", formatByteSize(free), formatByteSize(minFree), guard.mode)})
+	} else {
+		raiseStatus(StatusEvent{Kind: StatusSpaceRecovered, Appender: name,
+			Message: fmt.Sprintf("free space %v, writes resumed", formatByteSize(free))})
+	}
+}
+
+// allows reports whether rec is written in the current mode, records of
+// unknown level such as raw ones are kept by warn.
+func (guard *spaceGuard) allows(rec *Log4Record) bool {
+	if guard == nil || !guard.low {
+		return true
+	}
+	if guard.mode == LowSpaceStop {
+		return false
+	}
+	level, ok := LevelFileNameToLevel(rec.Level)
+	return !ok || level >= WARNING
+}
+
+func formatByteSize(size uint64) string {
+	const units = "KMGT"
+	if size < 1024 {
+		return strconv.FormatUint(size, 10) + "B"
+	}
+	value := float64(size)
+	unit := -1
+	for value >= 1024 && unit+1 < len(units) {
+		value /= 1024
+		unit++
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64) + units[unit:unit+1] + "B"
+}
@@ -0,0 +1,140 @@
+package log4
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMinFreeSpace(t *testing.T) {
+	tests := []struct {
+		text  string
+		size  uint64
+		ratio float64
+		ok    bool
+	}{
+		{"512", 512, 0, true},
+		{"64KB", 64 << 10, 0, true},
+		{"100MB", 100 << 20, 0, true},
+		{" 1GB ", 1 << 30, 0, true},
+		{"2TiB", 2 << 40, 0, true},
+		{"5%", 0, 0.05, true},
+		{"0.5%", 0, 0.005, true},
+		{"", 0, 0, false},
+		{"GB", 0, 0, false},
+		{"1XB", 0, 0, false},
+		{"1KMB", 0, 0, false},
+		{"-1MB", 0, 0, false},
+		{"0%", 0, 0, false},
+		{"100%", 0, 0, false},
+		{"x%", 0, 0, false},
+	}
+	for _, test := range tests {
+		size, ratio, err := parseMinFreeSpace(test.text)
+		if (err == nil) != test.ok || size != test.size || ratio != test.ratio {
+			t.Errorf("parseMinFreeSpace(%q) = %v, %v, %v", test.text, size, ratio, err)
+		}
+	}
+
+	appenders := []struct {
+		name     string
+		appender Log4ConfigAppender
+		ok       bool
+	}{
+		{"percent", Log4ConfigAppender{Kind: KindFile, MinFreeSpace: "5%", LowSpace: LowSpaceStop, SpaceCheckInterval: "1s"}, true},
+		{"unset", Log4ConfigAppender{Kind: KindConsole}, true},
+		{"console", Log4ConfigAppender{Kind: KindConsole, MinFreeSpace: "1GB"}, false},
+		{"bad size", Log4ConfigAppender{Kind: KindFile, MinFreeSpace: "1XB"}, false},
+		{"bad mode", Log4ConfigAppender{Kind: KindFile, MinFreeSpace: "1GB", LowSpace: "pause"}, false},
+		{"zero interval", Log4ConfigAppender{Kind: KindFile, MinFreeSpace: "1GB", SpaceCheckInterval: "0s"}, false},
+		{"mode alone", Log4ConfigAppender{Kind: KindFile, LowSpace: LowSpaceWarn}, false},
+	}
+	for _, test := range appenders {
+		err := test.appender.spaceCheck()
+		if (err == nil) != test.ok {
+			t.Errorf("%v: spaceCheck = %v", test.name, err)
+		}
+	}
+}
+
+// fakeFreeSpace replaces the free space of every path until the test ends.
+func fakeFreeSpace(t *testing.T, free *uint64, total uint64, err *error) {
+	saved := freeSpace
+	freeSpace = func(path string) (uint64, uint64, error) {
+		return *free, total, *err
+	}
+	t.Cleanup(func() { freeSpace = saved })
+}
+
+func TestSpaceGuard(t *testing.T) {
+	var events []StatusEvent
+	SetStatusHandler(func(event StatusEvent) { events = append(events, event) })
+	defer SetStatusHandler(nil)
+	free := uint64(10 << 30)
+	var freeErr error
+	fakeFreeSpace(t, &free, 100<<30, &freeErr)
+
+	w := &shortWriter{limit: -1}
+	console := newTestConsole("test_space", w, &Log4ConfigAppender{})
+	context := &console.Context
+	context.space = newSpaceGuard(&Log4ConfigAppender{Kind: KindFile, MinFreeSpace: "5%", SpaceCheckInterval: "1h"})
+	cache := formatCacheType{}
+	write := func(level string, message string) {
+		BufferWriteAndDropRec(console, context, newLevelRecord(level, message), &cache)
+	}
+
+	write("INFO", "plenty")
+	free = 1 << 30
+	// not checked again before the interval
+	write("INFO", "unchecked")
+	context.space.check("test_space", true)
+	write("INFO", "kept out")
+	write("WARN", "warn")
+	write("ERROR", "error")
+	raw := NewRawRecord([]byte("raw\n"))
+	BufferWriteAndDropRec(console, context, raw, &cache)
+
+	free = 6 << 30
+	context.space.check("test_space", true)
+	write("INFO", "recovered")
+	console.BufferFlush()
+
+	if got := w.buf.String(); got != "[INFO] plenty\n[INFO] unchecked\n[WARN] warn\n[ERROR] error\nraw\n[INFO] recovered\n" {
+		t.Errorf("written:%q", got)
+	}
+	if stats := context.stats.snapshot(); stats.Written != 6 || stats.Filtered != 1 || stats.Dropped != 0 {
+		t.Errorf("stats:%+v", stats)
+	}
+	if len(events) != 2 || events[0].Kind != StatusLowSpace || events[1].Kind != StatusSpaceRecovered {
+		t.Fatalf("events:%+v", events)
+	}
+	if events[0].Message != "free space 1.0GB below 5.0GB, low_space:warn" {
+		t.Errorf("low space:%q", events[0].Message)
+	}
+
+	// stop writes nothing, a failed check keeps the mode and warns once
+	events = nil
+	context.space = newSpaceGuard(&Log4ConfigAppender{Kind: KindFile, MinFreeSpace: "2GB", LowSpace: LowSpaceStop})
+	free = 1 << 30
+	context.space.check("test_space", true)
+	freeErr = errors.New("statfs failed")
+	context.space.check("test_space", true)
+	context.space.check("test_space", true)
+	write("FATAL", "stopped")
+	raw = NewRawRecord([]byte("raw\n"))
+	BufferWriteAndDropRec(console, context, raw, &cache)
+	if stats := context.stats.snapshot(); stats.Dropped != 2 || stats.Written != 6 {
+		t.Errorf("stop:%+v", stats)
+	}
+	if len(events) != 2 || events[1].Kind != StatusWarn || events[1].Message != "free space unknown, err:statfs failed" {
+		t.Fatalf("events:%+v", events)
+	}
+
+	freeErr = nil
+	free = 3 << 30
+	context.space.check("test_space", true)
+	write("INFO", "resumed")
+	console.BufferFlush()
+	if stats := context.stats.snapshot(); stats.Written != 7 || len(events) != 3 || events[2].Kind != StatusSpaceRecovered {
+		t.Errorf("resumed:%+v, events:%+v", stats, events)
+	}
+}
@@ -0,0 +1,250 @@
+package log4
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Log4Stats is a snapshot of the counters kept since the process started.
+// Appenders and targets are keyed by name, their counters survive InitFile
+// reloads.
+type Log4Stats struct {
+	Appenders map[string]AppenderStats
+	Targets   map[string]TargetStats
+}
+
+type AppenderStats struct {
+	// Enqueued records were handed to the appender, Written ones reached its
+	// writer, Dropped ones were lost on a write error or low_space: stop,
+	// Forwarded ones failed and went to the fallback or the error_handler and
+	// Filtered ones were formatted to nothing or below WARN on low_space: warn
+	Enqueued  uint64
+	Written   uint64
+	Dropped   uint64
+	Forwarded uint64
+	Filtered  uint64
+	// Bytes is the size of the written records
+	Bytes       uint64
+	Flushes     uint64
+	WriteErrors uint64
+	// Syncs counts the fsyncs of the durability of a file appender
+	Syncs uint64
+	// QueueDepth is the number of records waiting to be written
+	QueueDepth int64
+	// LatencyAvg and LatencyMax are the time from the creation of a record to
+	// its write, LatencyTotal is their sum over the Written records
+	LatencyAvg   time.Duration
+	LatencyMax   time.Duration
+	LatencyTotal time.Duration
+	// Levels counts the written records per level name
+	Levels map[string]uint64
+}
+
+type TargetStats struct {
+	// Levels counts the records logged per level name, before the appenders
+	Levels map[string]uint64
+}
+
+// appenderStats are the live counters behind AppenderStats.
+type appenderStats struct {
+	enqueued     atomic.Uint64
+	written      atomic.Uint64
+	dropped      atomic.Uint64
+	forwarded    atomic.Uint64
+	filtered     atomic.Uint64
+	bytes        atomic.Uint64
+	flushes      atomic.Uint64
+	writeErrors  atomic.Uint64
+	syncs        atomic.Uint64
+	queueDepth   atomic.Int64
+	latencyTotal atomic.Int64
+	latencyMax   atomic.Int64
+	levels       levelCounters
+}
+
+// levelCounters counts per level name, levels can be registered at any time.
+type levelCounters struct {
+	counters sync.Map
+}
+
+func (counters *levelCounters) add(level string) {
+	countI, ok := counters.counters.Load(level)
+	if !ok {
+		countI, _ = counters.counters.LoadOrStore(level, &atomic.Uint64{})
+	}
+	countI.(*atomic.Uint64).Add(1)
+}
+
+func (counters *levelCounters) snapshot() map[string]uint64 {
+	levels := make(map[string]uint64)
+	counters.counters.Range(func(key, value any) bool {
+		levels[key.(string)] = value.(*atomic.Uint64).Load()
+		return true
+	})
+	return levels
+}
+
+var appenderStatsMap sync.Map
+
+var targetStatsMap sync.Map
+
+func getAppenderStats(name string) *appenderStats {
+	statsI, ok := appenderStatsMap.Load(name)
+	if !ok {
+		statsI, _ = appenderStatsMap.LoadOrStore(name, &appenderStats{})
+	}
+	return statsI.(*appenderStats)
+}
+
+func getTargetStats(name string) *levelCounters {
+	statsI, ok := targetStatsMap.Load(name)
+	if !ok {
+		statsI, _ = targetStatsMap.LoadOrStore(name, &levelCounters{})
+	}
+	return statsI.(*levelCounters)
+}
+
+func (stats *appenderStats) enqueue() {
+	stats.enqueued.Add(1)
+	stats.queueDepth.Add(1)
+}
+
+func (stats *appenderStats) dequeue() {
+	stats.queueDepth.Add(-1)
+}
+
+func (stats *appenderStats) write(write pendingWrite, now time.Time) {
+	stats.written.Add(1)
+	stats.bytes.Add(uint64(write.size))
+	if len(write.level) > 0 {
+		stats.levels.add(write.level)
+	}
+
+	latency := int64(now.Sub(write.created))
+	stats.latencyTotal.Add(latency)
+	for {
+		max := stats.latencyMax.Load()
+		if latency <= max || stats.latencyMax.CompareAndSwap(max, latency) {
+			break
+		}
+	}
+}
+
+// pendingWrite is a record in the buffer of an appender, its latency is
+// taken once its bytes reach the writer.
+type pendingWrite struct {
+	level   string
+	size    int
+	created time.Time
+}
+
+// pendingWrites are the records in the buffer of an appender, they count as
+// Written once their bytes reach the writer and as Dropped when a failed
+// flush loses them. Only the goroutine of the appender uses them.
+type pendingWrites struct {
+	records []pendingWrite
+	size    int
+}
+
+func (pending *pendingWrites) add(rec *Log4Record, size int) {
+	pending.records = append(pending.records, pendingWrite{level: rec.Level, size: size, created: rec.Created})
+	pending.size += size
+}
+
+// settle counts the records whose bytes left the buffer, buffered is what is
+// still in it: as written when err is nil, else as dropped.
+func (pending *pendingWrites) settle(stats *appenderStats, buffered int, err error) {
+	settled := pending.size - buffered
+	if settled <= 0 {
+		return
+	}
+	now := time.Now()
+	n := 0
+	for size := 0; n < len(pending.records) && size < settled; n++ {
+		size += pending.records[n].size
+		if err == nil {
+			stats.write(pending.records[n], now)
+		} else {
+			stats.dropped.Add(1)
+		}
+	}
+	pending.records = append(pending.records[:0], pending.records[n:]...)
+	pending.size = buffered
+}
+
+// forward counts the records that left the buffer like settle, but the
+// unwritten bytes of writeErr are handed to the callback, or to the fallback
+// one raw record per record, and their records count as Forwarded. The records
+// before them reached the writer.
+func (pending *pendingWrites) forward(context *Log4AppenderContext, buffered int, writeErr *Log4WriteError) {
+	data := writeErr.Data
+	if context.onError.policy == OnErrorCallback {
+		context.onError.handler(WriteFailure{Appender: context.name, Data: data, Err: writeErr.Err})
+	}
+	fallback := context.onError.policy == OnErrorFallback
+
+	settled := pending.size - buffered
+	// the offset of data in the settled bytes, negative when data begins
+	// with bytes of no pending record
+	dataStart := settled - len(data)
+	if dataStart < 0 && fallback {
+		context.forwardRaw(data[:-dataStart], pendingWrite{})
+	}
+	now := time.Now()
+	n := 0
+	for start := 0; n < len(pending.records) && start < settled; n++ {
+		write := pending.records[n]
+		end := start + write.size
+		if end <= dataStart {
+			context.stats.write(write, now)
+		} else {
+			context.stats.forwarded.Add(1)
+			if fallback {
+				context.forwardRaw(data[max(start, dataStart)-dataStart:min(end-dataStart, len(data))], write)
+			}
+		}
+		start = end
+	}
+	pending.records = append(pending.records[:0], pending.records[n:]...)
+	pending.size = buffered
+}
+
+func (stats *appenderStats) snapshot() AppenderStats {
+	snapshot := AppenderStats{
+		Enqueued:     stats.enqueued.Load(),
+		Written:      stats.written.Load(),
+		Dropped:      stats.dropped.Load(),
+		Forwarded:    stats.forwarded.Load(),
+		Filtered:     stats.filtered.Load(),
+		Bytes:        stats.bytes.Load(),
+		Flushes:      stats.flushes.Load(),
+		WriteErrors:  stats.writeErrors.Load(),
+		Syncs:        stats.syncs.Load(),
+		QueueDepth:   stats.queueDepth.Load(),
+		LatencyMax:   time.Duration(stats.latencyMax.Load()),
+		LatencyTotal: time.Duration(stats.latencyTotal.Load()),
+		Levels:       stats.levels.snapshot(),
+	}
+	if snapshot.Written > 0 {
+		snapshot.LatencyAvg = snapshot.LatencyTotal / time.Duration(snapshot.Written)
+	}
+	return snapshot
+}
+
+// Stats returns the counters of every appender and target seen so far.
+func Stats() Log4Stats {
+	stats := Log4Stats{
+		Appenders: make(map[string]AppenderStats),
+		Targets:   make(map[string]TargetStats),
+	}
+	appenderStatsMap.Range(func(key, value any) bool {
+		stats.Appenders[key.(string)] = value.(*appenderStats).snapshot()
+		return true
+	})
+	targetStatsMap.Range(func(key, value any) bool {
+		stats.Targets[key.(string)] = TargetStats{Levels: value.(*levelCounters).snapshot()}
+		return true
+	})
+	return stats
+}
@@ -0,0 +1,55 @@
+package log4
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAppenderStats(t *testing.T) {
+	w := &shortWriter{limit: -1}
+	console := NewLog4ConsoleAppender("test_stats", &Log4ConfigAppender{Kind: KindConsole, Pattern: "[%L] %M", Color: ConsoleColorNever, RetryBackoff: "1ms"})
+	console.writer = NewLog4Writer(w)
+	console.Context.writer = console.writer
+	console.Context.stats = &appenderStats{}
+	context := &console.Context
+	cache := formatCacheType{}
+
+	// queued until the appender goroutine takes them
+	for i := 0; i < 3; i++ {
+		console.LogRecord(newBenchRecord())
+	}
+	if stats := context.stats.snapshot(); stats.Enqueued != 3 || stats.QueueDepth != 3 || stats.Written != 0 {
+		t.Fatalf("queued:%+v", stats)
+	}
+	BufferFlush(console, context, &cache)
+	stats := context.stats.snapshot()
+	if stats.Enqueued != 3 || stats.QueueDepth != 0 || stats.Written != 3 || stats.Levels["INFO"] != 3 ||
+		stats.Bytes != uint64(w.buf.Len()) || stats.Flushes != 1 {
+		t.Fatalf("written:%+v", stats)
+	}
+
+	// the latency runs until the buffer reaches the writer
+	BufferWriteAndDropRec(console, context, newBenchRecord(), &cache)
+	time.Sleep(20 * time.Millisecond)
+	console.BufferFlush()
+	if stats := context.stats.snapshot(); stats.Written != 4 || stats.LatencyMax < 20*time.Millisecond {
+		t.Fatalf("latency:%+v", stats)
+	}
+
+	// formatted to nothing
+	layout := context.Layout
+	context.Layout = NewLog4Layout("", Log4LayoutOptions{})
+	BufferWriteAndDropRec(console, context, newBenchRecord(), &cache)
+	context.Layout = layout
+
+	// lost by a failed flush
+	w.limit = w.buf.Len()
+	BufferWriteAndDropRec(console, context, newBenchRecord(), &cache)
+	console.BufferFlush()
+
+	stats = context.stats.snapshot()
+	if stats.Written != 4 || stats.Filtered != 1 || stats.Dropped != 1 || stats.WriteErrors == 0 ||
+		stats.Levels["INFO"] != 4 || stats.Bytes != uint64(w.buf.Len()) {
+		t.Fatalf("filtered and dropped:%+v", stats)
+	}
+}
@@ -0,0 +1,76 @@
+package log4
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/yefy/log4go/ee"
+)
+
+const StatusWarn = "warn"
+const StatusWriteFailed = "write_failed"
+const StatusWriteRecovered = "write_recovered"
+const StatusLowSpace = "low_space"
+const StatusSpaceRecovered = "space_recovered"
+
+// StatusEvent reports a problem of log4 itself, it can not go through the
+// appenders. Appender is empty for events not tied to one. Message is one
+// line with the bare cause, Err keeps the whole chain.
+type StatusEvent struct {
+	Time     time.Time
+	Kind     string
+	Appender string
+	Message  string
+	Err      error
+}
+
+func (event StatusEvent) String() string {
+	msg := event.Message
+	if len(event.Appender) > 0 {
+		msg = "appender:" + event.Appender + " " + msg
+	}
+	return msg
+}
+
+// causeOf returns the message of the first error of the chain of err that
+// is not an ee frame or a *Log4WriteError, such as the *os.PathError of a
+// write, or the message of the innermost frame.
+func causeOf(err error) string {
+	for {
+		switch e := err.(type) {
+		case *ee.Error:
+			if e.Cause == nil {
+				return e.Msg
+			}
+			err = e.Cause
+		case *Log4WriteError:
+			err = e.Err
+		default:
+			return err.Error()
+		}
+	}
+}
+
+var statusHandler atomic.Pointer[func(StatusEvent)]
+
+// SetStatusHandler sends the status events to handler instead of stderr, nil
+// restores stderr. The handler must not log through log4.
+func SetStatusHandler(handler func(StatusEvent)) {
+	if handler == nil {
+		statusHandler.Store(nil)
+		return
+	}
+	statusHandler.Store(&handler)
+}
+
+func raiseStatus(event StatusEvent) {
+	event.Time = time.Now()
+	handler := statusHandler.Load()
+	if handler != nil {
+		(*handler)(event)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "log4go: %v\n", event)
+}
@@ -0,0 +1,159 @@
+package log4
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// plumbingPkgs are the packages whose frames sit between user code and the
+// writer returned by Log4Target.Writer, they are skipped when resolving the source.
+var plumbingPkgs = map[string]bool{
+	"log":                         true,
+	"io":                          true,
+	"bufio":                       true,
+	"fmt":                         true,
+	"os":                          true,
+	"os/exec":                     true,
+	"github.com/yefy/log4go/log4": true,
+}
+
+// maxPartialLine is the longest partial line Log4LevelWriter keeps, longer
+// ones are logged as they are.
+const maxPartialLine = 64 * 1024
+
+func NewLog4LevelWriter(getTarget func() *Log4Target, level Level) *Log4LevelWriter {
+	return &Log4LevelWriter{
+		getTarget: getTarget,
+		level:     level,
+	}
+}
+
+// Log4LevelWriter is an io.Writer that splits the written bytes into lines and
+// logs every complete line as a record, a trailing partial line is kept until
+// the next newline or Flush, or logged once it reaches maxPartialLine.
+type Log4LevelWriter struct {
+	getTarget func() *Log4Target
+	level     Level
+	mutex     sync.Mutex
+	buf       []byte
+}
+
+func (w *Log4LevelWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := bytes.TrimSuffix(w.buf[:i], []byte{'\r'})
+		w.logLine(line)
+		w.buf = w.buf[i+1:]
+	}
+	for len(w.buf) >= maxPartialLine {
+		w.logLine(w.buf[:maxPartialLine])
+		w.buf = w.buf[maxPartialLine:]
+	}
+	if len(w.buf) == 0 {
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+func (w *Log4LevelWriter) Flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if len(w.buf) > 0 {
+		w.logLine(w.buf)
+		w.buf = nil
+	}
+}
+
+// logLine logs line with the source of the first caller outside
+// plumbingPkgs, whatever the caller skip of the target.
+func (w *Log4LevelWriter) logLine(line []byte) {
+	target := w.getTarget()
+	target.log(externalCallerSkip()-target.callerSkip, w.level, string(line))
+}
+
+func (w *Log4LevelWriter) Close() error {
+	w.Flush()
+	return nil
+}
+
+func (log4Target *Log4Target) Writer(level Level) io.Writer {
+	return NewLog4LevelWriter(func() *Log4Target { return log4Target }, level)
+}
+
+// NewStdLogger returns a standard library logger writing into targetName,
+// e.g. for http.Server.ErrorLog. The target is resolved on every line so the
+// logger keeps working across InitFile reloads.
+func NewStdLogger(targetName string, level Level) *log.Logger {
+	return log.New(NewLog4LevelWriter(func() *Log4Target { return Target(targetName) }, level), "", 0)
+}
+
+// RedirectStdLog sends the output of the standard log package to targetName
+// and returns a function restoring the previous output, prefix and flags.
+func RedirectStdLog(targetName string, level Level) func() {
+	prevWriter := log.Writer()
+	prevPrefix := log.Prefix()
+	prevFlags := log.Flags()
+
+	log.SetOutput(NewLog4LevelWriter(func() *Log4Target { return Target(targetName) }, level))
+	log.SetPrefix("")
+	log.SetFlags(0)
+
+	return func() {
+		log.SetOutput(prevWriter)
+		log.SetPrefix(prevPrefix)
+		log.SetFlags(prevFlags)
+	}
+}
+
+// externalCallerSkip returns the skip GetRecord needs, when called through
+// log4Target.log by the caller of externalCallerSkip, to report the first frame
+// outside plumbingPkgs. Goroutines made only of plumbing (e.g. the os/exec copy
+// goroutine) report their outermost frame.
+func externalCallerSkip() int {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	depth := 0
+	outermost := 1
+	for {
+		frame, more := frames.Next()
+		if depth > 0 {
+			pkg := funcPackage(frame.Function)
+			if pkg == "runtime" {
+				break
+			}
+			if !plumbingPkgs[pkg] {
+				return depth + 2
+			}
+			outermost = depth
+		}
+		if !more {
+			break
+		}
+		depth++
+	}
+	return outermost + 2
+}
+
+// funcPackage returns the import path of a fully qualified function name such
+// as "github.com/yefy/log4go/log4.(*Log4Target).log".
+func funcPackage(funcName string) string {
+	slash := strings.LastIndex(funcName, "/")
+	dot := strings.Index(funcName[slash+1:], ".")
+	if dot < 0 {
+		return funcName
+	}
+	return funcName[:slash+1+dot]
+}
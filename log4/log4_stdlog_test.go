@@ -0,0 +1,60 @@
+package log4_test
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/yefy/log4go/log4"
+	"github.com/yefy/log4go/log4test"
+)
+
+func TestStdLoggerSource(t *testing.T) {
+	rec := log4test.New(t)
+	for _, skip := range []int{0, 2} {
+		logger := log.New(rec.Target("").WithCallerSkip(skip).Writer(log4.WARNING), "", 0)
+		_, file, line, _ := runtime.Caller(0)
+		logger.Printf("printf skip:%v", skip)
+
+		records := rec.Find(log4.WARNING, "", fmt.Sprintf("printf skip:%v", skip))
+		if len(records) != 1 {
+			t.Fatalf("records:%+v", rec.Records())
+		}
+		want := fmt.Sprintf("%v:%v@", filepath.Base(file), line+1)
+		if !strings.Contains(records[0].Source, want) {
+			t.Errorf("skip:%v source:%q, want %v", skip, records[0].Source, want)
+		}
+	}
+}
+
+func TestLevelWriterLines(t *testing.T) {
+	rec := log4test.New(t)
+	w := rec.Target("").Writer(log4.INFO).(*log4.Log4LevelWriter)
+
+	fmt.Fprintf(w, "first\r\nsec")
+	fmt.Fprintf(w, "ond\nthird")
+	if got := len(rec.Records()); got != 2 {
+		t.Fatalf("%v records before Flush:%+v", got, rec.Records())
+	}
+	w.Flush()
+	records := rec.Records()
+	if len(records) != 3 || records[0].Message != "first" || records[1].Message != "second" || records[2].Message != "third" {
+		t.Fatalf("records:%+v", records)
+	}
+
+	// a line without newline is not kept forever
+	rec.Reset()
+	w.Write([]byte(strings.Repeat("x", 64*1024+10)))
+	records = rec.Records()
+	if len(records) != 1 || len(records[0].Message) != 64*1024 {
+		t.Fatalf("%v records of a long partial line", len(records))
+	}
+	w.Flush()
+	records = rec.Records()
+	if len(records) != 2 || records[1].Message != strings.Repeat("x", 10) {
+		t.Fatalf("rest of the long line:%+v", len(records))
+	}
+}
@@ -1,11 +1,17 @@
 package log4
 
 import (
+	"fmt"
 	"github.com/yefy/log4go/ee"
 	"os"
 	"unsafe"
 )
 
+// log4Warn reports problems of log4 itself as a status event.
+func log4Warn(format string, args ...interface{}) {
+	raiseStatus(StatusEvent{Kind: StatusWarn, Message: fmt.Sprintf(format, args...)})
+}
+
 func ModTime(filePath string) (int64, error) {
 	info, err := os.Stat(filePath)
 	if err != nil {
@@ -52,35 +52,63 @@ func (b *Log4Writer) Flush() error {
 	return err
 }
 
+// Log4WriteError is returned when the underlying writer failed, Data holds
+// a copy of the bytes that were not written.
+type Log4WriteError struct {
+	Data []byte
+	Err  error
+}
+
+func (e *Log4WriteError) Error() string {
+	return fmt.Sprintf("unwritten:%v, err:%v", len(e.Data), e.Err)
+}
+
+func (e *Log4WriteError) Unwrap() error {
+	return e.Err
+}
+
+// FlushBuf writes buf, a short write continues with the rest of buf, the
+// first error stops it with a *Log4WriteError.
 func (b *Log4Writer) FlushBuf(buf []byte) error {
-	bufSize := len(buf)
-	if bufSize <= 0 {
-		return nil
-	}
-	for i := 0; i < 3; i++ {
-		n, err := b.wr.Write(buf)
-		if err == nil && n == bufSize {
-			return nil
+	written := 0
+	for written < len(buf) {
+		n, err := b.wr.Write(buf[written:])
+		if n > 0 {
+			written += n
+		}
+		if err == nil && n <= 0 {
+			err = io.ErrShortWrite
 		}
-
 		if err != nil {
-			fmt.Printf("err:Flush => index:%v, err:%v\n", i, err)
-		} else {
-			fmt.Printf("err:Flush => index:%v, n:%v != bufSize:%v\n", i, n, bufSize)
+			return &Log4WriteError{Data: append([]byte(nil), buf[written:]...), Err: ee.New(err, "write")}
 		}
 	}
-
-	return ee.New(nil, "err:Flush")
+	return nil
 }
 
+// Write buffers s, when the buffer can not be flushed s is still buffered
+// and the error holds the unwritten buffer.
 func (b *Log4Writer) Write(s []byte) (int, error) {
 	sLen := len(s)
 	if sLen >= b.Size() {
-		b.Flush()
-		b.FlushBuf(s)
+		err := b.Flush()
+		if err != nil {
+			// s is not written either
+			writeErr := err.(*Log4WriteError)
+			writeErr.Data = append(writeErr.Data, s...)
+			return 0, writeErr
+		}
+		err = b.FlushBuf(s)
+		if err != nil {
+			return 0, err
+		}
 		return sLen, nil
 	} else if sLen > b.Available() {
-		b.Flush()
+		err := b.Flush()
+		if err != nil {
+			b.n = copy(b.buf, s)
+			return sLen, err
+		}
 	}
 
 	n := copy(b.buf[b.n:], s)
@@ -0,0 +1,140 @@
+package log4
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errWriteFailed = errors.New("write failed")
+
+// shortWriter writes at most chunk bytes per call, and fails once limit
+// bytes were written when limit >= 0.
+type shortWriter struct {
+	buf   bytes.Buffer
+	chunk int
+	limit int
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if w.limit >= 0 && w.buf.Len() >= w.limit {
+		return 0, errWriteFailed
+	}
+	n := len(p)
+	if w.chunk > 0 && n > w.chunk {
+		n = w.chunk
+	}
+	if w.limit >= 0 && w.buf.Len()+n > w.limit {
+		n = w.limit - w.buf.Len()
+	}
+	w.buf.Write(p[:n])
+	return n, nil
+}
+
+func TestFlushBufShortWrite(t *testing.T) {
+	w := &shortWriter{chunk: 3, limit: -1}
+	err := NewLog4WriterSize(w, 16).FlushBuf([]byte("hello world"))
+	if err != nil || w.buf.String() != "hello world" {
+		t.Fatalf("FlushBuf = %v, wrote %q", err, w.buf.String())
+	}
+
+	w = &shortWriter{chunk: 3, limit: 5}
+	err = NewLog4WriterSize(w, 16).FlushBuf([]byte("hello world"))
+	var writeErr *Log4WriteError
+	if !errors.As(err, &writeErr) {
+		t.Fatalf("FlushBuf err = %v, want *Log4WriteError", err)
+	}
+	if string(writeErr.Data) != " world" || w.buf.String() != "hello" {
+		t.Fatalf("unwritten %q, wrote %q", writeErr.Data, w.buf.String())
+	}
+	if !errors.Is(err, errWriteFailed) {
+		t.Fatalf("FlushBuf err = %v, does not wrap the writer error", err)
+	}
+}
+
+func TestWriteFlushError(t *testing.T) {
+	w := &shortWriter{limit: 4}
+	writer := NewLog4WriterSize(w, 16)
+	writer.WriteString("0123456789")
+
+	// the buffer is lost, the small write is buffered
+	_, err := writer.WriteString("abcdefghij")
+	var writeErr *Log4WriteError
+	if !errors.As(err, &writeErr) || string(writeErr.Data) != "456789" {
+		t.Fatalf("WriteString err = %v", err)
+	}
+	if writer.Buffered() != 10 {
+		t.Fatalf("Buffered = %v, want 10", writer.Buffered())
+	}
+
+	// the buffer and the big write are both lost
+	n, err := writer.WriteString("0123456789abcdefghij")
+	if !errors.As(err, &writeErr) || n != 0 || string(writeErr.Data) != "abcdefghij0123456789abcdefghij" {
+		t.Fatalf("WriteString = %v, %v", n, err)
+	}
+	if writer.Buffered() != 0 {
+		t.Fatalf("Buffered = %v, want 0", writer.Buffered())
+	}
+}
+
+func TestWriteResultLostRecords(t *testing.T) {
+	w := &shortWriter{limit: 0}
+	appender := &Log4ConsoleAppender{
+		Context: Log4AppenderContext{
+			name:    "test_lost_records",
+			Layout:  NewLog4Layout("%M", Log4LayoutOptions{}),
+			stats:   &appenderStats{},
+			onError: newOnErrorPolicy(&Log4ConfigAppender{RetryCount: 2, RetryBackoff: "20ms"}),
+		},
+		writer: NewLog4WriterSize(w, 64),
+	}
+	appender.Context.writer = appender.writer
+	context := &appender.Context
+	cache := formatCacheType{}
+
+	write := func(count int) {
+		for i := 0; i < count; i++ {
+			BufferWriteAndDropRec(appender, context, newBenchRecord(), &cache)
+		}
+	}
+
+	// buffered records are not written yet
+	write(3)
+	if stats := context.stats.snapshot(); stats.Written != 0 || stats.Dropped != 0 {
+		t.Fatalf("buffered: written %v, dropped %v", stats.Written, stats.Dropped)
+	}
+
+	// the first failure retries, the lost records are dropped
+	start := time.Now()
+	appender.BufferFlush()
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Fatalf("first failure retried for %v, want the backoff", elapsed)
+	}
+	if stats := context.stats.snapshot(); stats.Written != 0 || stats.Dropped != 3 {
+		t.Fatalf("failed flush: written %v, dropped %v", stats.Written, stats.Dropped)
+	}
+
+	// while failing there is no backoff
+	write(2)
+	start = time.Now()
+	appender.BufferFlush()
+	if elapsed := time.Since(start); elapsed >= 20*time.Millisecond {
+		t.Fatalf("failing appender slept %v", elapsed)
+	}
+	if stats := context.stats.snapshot(); stats.Dropped != 5 {
+		t.Fatalf("failing: dropped %v, want 5", stats.Dropped)
+	}
+
+	// recovered, the records count once they reach the writer
+	w.limit = -1
+	write(2)
+	appender.BufferFlush()
+	stats := context.stats.snapshot()
+	if stats.Written != 2 || stats.Dropped != 5 || stats.Bytes != uint64(w.buf.Len()) {
+		t.Fatalf("recovered: written %v, dropped %v, bytes %v of %v", stats.Written, stats.Dropped, stats.Bytes, w.buf.Len())
+	}
+	if context.failedAt.Load() != 0 {
+		t.Fatalf("failedAt not reset")
+	}
+}
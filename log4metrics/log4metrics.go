@@ -0,0 +1,174 @@
+// Package log4metrics exports log4.Stats in the Prometheus text format and
+// through expvar, without the Prometheus client library:
+//
+//	http.Handle("/metrics", log4metrics.Handler())
+//	log4metrics.PublishExpvar()
+//
+// Metric names:
+//
+//	log4go_records_total{appender,level}            records written
+//	log4go_records_enqueued_total{appender}         records handed to the appender
+//	log4go_records_dropped_total{appender}          records lost on write errors or low space
+//	log4go_records_forwarded_total{appender}        records that failed and went to the fallback or error handler
+//	log4go_records_filtered_total{appender}         records formatted to nothing or kept out on low space
+//	log4go_bytes_written_total{appender}
+//	log4go_flushes_total{appender}
+//	log4go_write_errors_total{appender}
+//	log4go_syncs_total{appender}                    fsyncs of the durability setting
+//	log4go_queue_depth{appender}                    records waiting to be written
+//	log4go_write_latency_seconds{appender}          summary of creation to write, _sum and _count
+//	log4go_write_latency_seconds_max{appender}
+//	log4go_target_records_total{target,level}       records logged per target
+package log4metrics
+
+import (
+	"expvar"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yefy/log4go/log4"
+)
+
+// ExpvarName is the expvar variable PublishExpvar publishes.
+const ExpvarName = "log4go"
+
+// Handler serves log4.Stats in the Prometheus text exposition format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(AppendText(nil, log4.Stats()))
+	})
+}
+
+var publishOnce sync.Once
+
+// PublishExpvar publishes log4.Stats as the expvar variable log4go, it can be
+// called more than once.
+func PublishExpvar() {
+	publishOnce.Do(func() {
+		expvar.Publish(ExpvarName, expvar.Func(func() any {
+			return log4.Stats()
+		}))
+	})
+}
+
+// metric is one family of appenderMetrics, a summary has samples instead of
+// a value.
+type metric struct {
+	name    string
+	help    string
+	kind    string
+	value   func(stats log4.AppenderStats) string
+	samples []sample
+}
+
+// sample is a series of a summary, suffix is appended to its name.
+type sample struct {
+	suffix string
+	value  func(stats log4.AppenderStats) string
+}
+
+var appenderMetrics = []metric{
+	{"log4go_records_enqueued_total", "Records handed to the appender.", "counter", func(s log4.AppenderStats) string { return formatUint(s.Enqueued) }, nil},
+	{"log4go_records_dropped_total", "Records lost on write errors or low space.", "counter", func(s log4.AppenderStats) string { return formatUint(s.Dropped) }, nil},
+	{"log4go_records_forwarded_total", "Records that failed and went to the fallback or the error handler.", "counter", func(s log4.AppenderStats) string { return formatUint(s.Forwarded) }, nil},
+	{"log4go_records_filtered_total", "Records formatted to nothing or kept out on low space.", "counter", func(s log4.AppenderStats) string { return formatUint(s.Filtered) }, nil},
+	{"log4go_bytes_written_total", "Bytes of the written records.", "counter", func(s log4.AppenderStats) string { return formatUint(s.Bytes) }, nil},
+	{"log4go_flushes_total", "Buffer flushes.", "counter", func(s log4.AppenderStats) string { return formatUint(s.Flushes) }, nil},
+	{"log4go_write_errors_total", "Failed writes and flushes.", "counter", func(s log4.AppenderStats) string { return formatUint(s.WriteErrors) }, nil},
+	{"log4go_syncs_total", "Fsyncs of the durability setting.", "counter", func(s log4.AppenderStats) string { return formatUint(s.Syncs) }, nil},
+	{"log4go_queue_depth", "Records waiting to be written.", "gauge", func(s log4.AppenderStats) string { return strconv.FormatInt(s.QueueDepth, 10) }, nil},
+	{"log4go_write_latency_seconds", "Time from record creation to write.", "summary", nil, []sample{
+		{"_sum", func(s log4.AppenderStats) string { return formatFloat(s.LatencyTotal.Seconds()) }},
+		{"_count", func(s log4.AppenderStats) string { return formatUint(s.Written) }},
+	}},
+	{"log4go_write_latency_seconds_max", "Longest time from record creation to write.", "gauge", func(s log4.AppenderStats) string { return formatFloat(s.LatencyMax.Seconds()) }, nil},
+}
+
+// AppendText appends stats in the Prometheus text exposition format, series
+// are sorted by their labels.
+func AppendText(dst []byte, stats log4.Log4Stats) []byte {
+	appenders := sortedKeys(stats.Appenders)
+
+	dst = appendHeader(dst, "log4go_records_total", "Records written per level.", "counter")
+	for _, appender := range appenders {
+		levels := stats.Appenders[appender].Levels
+		for _, level := range sortedKeys(levels) {
+			dst = appendSample(dst, "log4go_records_total", formatUint(levels[level]), "appender", appender, "level", level)
+		}
+	}
+
+	for _, m := range appenderMetrics {
+		dst = appendHeader(dst, m.name, m.help, m.kind)
+		for _, appender := range appenders {
+			if m.value != nil {
+				dst = appendSample(dst, m.name, m.value(stats.Appenders[appender]), "appender", appender)
+			}
+			for _, sample := range m.samples {
+				dst = appendSample(dst, m.name+sample.suffix, sample.value(stats.Appenders[appender]), "appender", appender)
+			}
+		}
+	}
+
+	dst = appendHeader(dst, "log4go_target_records_total", "Records logged per target and level.", "counter")
+	for _, target := range sortedKeys(stats.Targets) {
+		levels := stats.Targets[target].Levels
+		for _, level := range sortedKeys(levels) {
+			dst = appendSample(dst, "log4go_target_records_total", formatUint(levels[level]), "target", target, "level", level)
+		}
+	}
+	return dst
+}
+
+func appendHeader(dst []byte, name string, help string, kind string) []byte {
+	dst = append(dst, "# HELP "...)
+	dst = append(dst, name...)
+	dst = append(dst, ' ')
+	dst = append(dst, help...)
+	dst = append(dst, "\n# TYPE "...)
+	dst = append(dst, name...)
+	dst = append(dst, ' ')
+	dst = append(dst, kind...)
+	return append(dst, '\n')
+}
+
+// appendSample appends name{label="value",...} value, labels are name/value
+// pairs.
+func appendSample(dst []byte, name string, value string, labels ...string) []byte {
+	dst = append(dst, name...)
+	dst = append(dst, '{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, labels[i]...)
+		dst = append(dst, `="`...)
+		dst = append(dst, labelEscaper.Replace(labels[i+1])...)
+		dst = append(dst, '"')
+	}
+	dst = append(dst, "} "...)
+	dst = append(dst, value...)
+	return append(dst, '\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatUint(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,96 @@
+package log4metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yefy/log4go/log4"
+)
+
+func TestAppendText(t *testing.T) {
+	stats := log4.Log4Stats{
+		Appenders: map[string]log4.AppenderStats{
+			"file": {
+				Enqueued: 5, Written: 3, Dropped: 1, Forwarded: 2, Filtered: 1, Bytes: 120, Flushes: 2, WriteErrors: 1, Syncs: 4, QueueDepth: 1,
+				LatencyTotal: 1500 * time.Millisecond, LatencyMax: time.Second,
+				Levels: map[string]uint64{"INFO": 2, "ERROR": 1},
+			},
+			"we\"ird\\\n": {Levels: map[string]uint64{}},
+		},
+		Targets: map[string]log4.TargetStats{
+			"db": {Levels: map[string]uint64{"WARN": 1}},
+		},
+	}
+	const want = `# HELP log4go_records_total Records written per level.
+# TYPE log4go_records_total counter
+log4go_records_total{appender="file",level="ERROR"} 1
+log4go_records_total{appender="file",level="INFO"} 2
+# HELP log4go_records_enqueued_total Records handed to the appender.
+# TYPE log4go_records_enqueued_total counter
+log4go_records_enqueued_total{appender="file"} 5
+log4go_records_enqueued_total{appender="we\"ird\\\n"} 0
+# HELP log4go_records_dropped_total Records lost on write errors or low space.
+# TYPE log4go_records_dropped_total counter
+log4go_records_dropped_total{appender="file"} 1
+log4go_records_dropped_total{appender="we\"ird\\\n"} 0
+# HELP log4go_records_forwarded_total Records that failed and went to the fallback or the error handler.
+# TYPE log4go_records_forwarded_total counter
+log4go_records_forwarded_total{appender="file"} 2
+log4go_records_forwarded_total{appender="we\"ird\\\n"} 0
+# HELP log4go_records_filtered_total Records formatted to nothing or kept out on low space.
+# TYPE log4go_records_filtered_total counter
+log4go_records_filtered_total{appender="file"} 1
+log4go_records_filtered_total{appender="we\"ird\\\n"} 0
+# HELP log4go_bytes_written_total Bytes of the written records.
+# TYPE log4go_bytes_written_total counter
+log4go_bytes_written_total{appender="file"} 120
+log4go_bytes_written_total{appender="we\"ird\\\n"} 0
+# HELP log4go_flushes_total Buffer flushes.
+# TYPE log4go_flushes_total counter
+log4go_flushes_total{appender="file"} 2
+log4go_flushes_total{appender="we\"ird\\\n"} 0
+# HELP log4go_write_errors_total Failed writes and flushes.
+# TYPE log4go_write_errors_total counter
+log4go_write_errors_total{appender="file"} 1
+log4go_write_errors_total{appender="we\"ird\\\n"} 0
+# HELP log4go_syncs_total Fsyncs of the durability setting.
+# TYPE log4go_syncs_total counter
+log4go_syncs_total{appender="file"} 4
+log4go_syncs_total{appender="we\"ird\\\n"} 0
+# HELP log4go_queue_depth Records waiting to be written.
+# TYPE log4go_queue_depth gauge
+log4go_queue_depth{appender="file"} 1
+log4go_queue_depth{appender="we\"ird\\\n"} 0
+# HELP log4go_write_latency_seconds Time from record creation to write.
+# TYPE log4go_write_latency_seconds summary
+log4go_write_latency_seconds_sum{appender="file"} 1.5
+log4go_write_latency_seconds_count{appender="file"} 3
+log4go_write_latency_seconds_sum{appender="we\"ird\\\n"} 0
+log4go_write_latency_seconds_count{appender="we\"ird\\\n"} 0
+# HELP log4go_write_latency_seconds_max Longest time from record creation to write.
+# TYPE log4go_write_latency_seconds_max gauge
+log4go_write_latency_seconds_max{appender="file"} 1
+log4go_write_latency_seconds_max{appender="we\"ird\\\n"} 0
+# HELP log4go_target_records_total Records logged per target and level.
+# TYPE log4go_target_records_total counter
+log4go_target_records_total{target="db",level="WARN"} 1
+`
+	got := string(AppendText(nil, stats))
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// one family per metric
+	families := make(map[string]bool)
+	for _, line := range strings.Split(got, "\n") {
+		if !strings.HasPrefix(line, "# TYPE ") {
+			continue
+		}
+		name := strings.Fields(line)[2]
+		if families[name] {
+			t.Errorf("family %v twice", name)
+		}
+		families[name] = true
+	}
+}
@@ -0,0 +1,84 @@
+package log4test
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yefy/log4go/log4"
+)
+
+// recordAppender keeps the records in memory as they are logged, there is no
+// queue so nothing needs a flush.
+type recordAppender struct {
+	layout *log4.Log4Layout
+	tb     testing.TB
+	// closed stops the mirror once the test is over, tb.Log panics then
+	closed  atomic.Bool
+	mutex   sync.Mutex
+	records []Record
+}
+
+func (appender *recordAppender) Name() string {
+	return AppenderName
+}
+
+func (appender *recordAppender) Layout() *log4.Log4Layout {
+	return appender.layout
+}
+
+func (appender *recordAppender) LogRecord(rec *log4.Log4Record) {
+	defer rec.Put()
+	level, _ := log4.LevelFileNameToLevel(rec.Level)
+	record := Record{
+		Level:     level,
+		Target:    rec.Target,
+		Message:   rec.Message,
+		Source:    rec.Source,
+		Created:   rec.Created,
+		Fields:    append(rec.Fields[:0:0], rec.Fields...),
+		ErrFrames: append(rec.ErrFrames[:0:0], rec.ErrFrames...),
+		Stack:     rec.Stack,
+		Text:      strings.TrimSuffix(string(appender.layout.AppendFormat(nil, rec, nil)), "\n"),
+	}
+	if rec.Raw != nil {
+		record.Text = strings.TrimSuffix(string(rec.Raw), "\n")
+		record.Message = record.Text
+	}
+
+	appender.mutex.Lock()
+	appender.records = append(appender.records, record)
+	appender.mutex.Unlock()
+
+	if appender.tb != nil && !appender.closed.Load() {
+		appender.tb.Log(record.Text)
+	}
+}
+
+func (appender *recordAppender) Run() {}
+
+func (appender *recordAppender) Flush() {}
+
+func (appender *recordAppender) FlushSync(timeout time.Duration) bool {
+	return true
+}
+
+func (appender *recordAppender) Close(isWait bool) {}
+
+func (appender *recordAppender) BufferWrite(msg string) error {
+	return nil
+}
+
+func (appender *recordAppender) BufferFlush() error {
+	return nil
+}
+
+func (appender *recordAppender) BufferSize() int {
+	return 0
+}
+
+func (appender *recordAppender) BufferClose() error {
+	return nil
+}
@@ -0,0 +1,193 @@
+// Package log4test records the output of a Log4 in memory for unit tests:
+//
+//	rec := log4test.New(t, log4test.WithLoggers("db"), log4test.WithMirror())
+//	doWork(rec.Target("db"))
+//	if !rec.Contains(log4.ERROR, "db", "timeout") {
+//		t.Errorf("records:%+v", rec.Records())
+//	}
+//
+// The Log4 is fresh, it opens no file and leaves GLog4 alone, so the code
+// under test must take its *log4.Log4 or *log4.Log4Target.
+package log4test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yefy/log4go/ee"
+	"github.com/yefy/log4go/log4"
+)
+
+// AppenderName is the name of the recording appender in the config, a
+// failover or a tee added by WithConfig can use it as a child.
+const AppenderName = "log4test"
+
+// Pattern formats Record.Text and the mirrored lines.
+const Pattern = "[%C] [%L] (%S) %M"
+
+// Record is a copy of a logged record.
+type Record struct {
+	Level     log4.Level
+	Target    string
+	Message   string
+	Source    string
+	Created   time.Time
+	Fields    []ee.Attr
+	ErrFrames []ee.Frame
+	Stack     string
+	// Text is the record formatted with Pattern, without the newline. A raw
+	// record, such as the bytes a failover child failed to write, has its
+	// bytes as Text and Message instead
+	Text string
+}
+
+type options struct {
+	level   string
+	loggers []string
+	mirror  bool
+	config  func(log4Config *log4.Log4Config)
+}
+
+type Option func(*options)
+
+// WithLevel sets the level of root and of the loggers, all by default.
+func WithLevel(level string) Option {
+	return func(opts *options) {
+		opts.level = level
+	}
+}
+
+// WithLoggers adds targets recorded like root, log4.Log4.Target returns nil
+// for names without a logger.
+func WithLoggers(names ...string) Option {
+	return func(opts *options) {
+		opts.loggers = append(opts.loggers, names...)
+	}
+}
+
+// WithMirror also writes every record to tb.Log, so that it shows with the
+// output of the test.
+func WithMirror() Option {
+	return func(opts *options) {
+		opts.mirror = true
+	}
+}
+
+// WithConfig lets edit change the config before it runs, e.g. to add
+// appenders and send root to them:
+//
+//	log4test.WithConfig(func(config *log4.Log4Config) {
+//		config.Appenders["net"] = log4.Log4ConfigAppender{Kind: log4.KindFailover, Appenders: []string{"file", log4test.AppenderName}}
+//		config.Root.Appenders = []string{"net"}
+//	})
+func WithConfig(edit func(log4Config *log4.Log4Config)) Option {
+	return func(opts *options) {
+		opts.config = edit
+	}
+}
+
+// Recorder is a Log4 whose targets all log synchronously into memory.
+type Recorder struct {
+	log4     *log4.Log4
+	appender *recordAppender
+}
+
+// New runs a fresh Log4 recording every target, it is closed by tb.Cleanup.
+func New(tb testing.TB, opts ...Option) *Recorder {
+	tb.Helper()
+	o := options{level: "all"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	appender := &recordAppender{layout: log4.NewLog4Layout(Pattern, log4.Log4LayoutOptions{})}
+	if o.mirror {
+		appender.tb = tb
+	}
+
+	log4Config := &log4.Log4Config{
+		Root: log4.Log4ConfigLogger{
+			Level:     o.level,
+			Multiline: true,
+			Appenders: []string{AppenderName},
+		},
+		Appenders: make(map[string]log4.Log4ConfigAppender),
+		Loggers:   make(map[string]log4.Log4ConfigLogger, len(o.loggers)),
+	}
+	for _, name := range o.loggers {
+		log4Config.Loggers[name] = log4.Log4ConfigLogger{
+			Level:     o.level,
+			Multiline: true,
+			Appenders: []string{AppenderName},
+		}
+	}
+
+	if o.config != nil {
+		o.config(log4Config)
+	}
+
+	l4 := log4.NewLog4("")
+	l4.AddAppender(appender)
+	err := l4.Run(log4Config)
+	if err != nil {
+		tb.Fatalf("log4test: %v", err)
+	}
+	tb.Cleanup(func() {
+		appender.closed.Store(true)
+		l4.Close(true)
+	})
+	return &Recorder{log4: l4, appender: appender}
+}
+
+// Log4 returns the recorded Log4.
+func (rec *Recorder) Log4() *log4.Log4 {
+	return rec.log4
+}
+
+// Target returns a recorded target, root for "" and for names not given to
+// WithLoggers.
+func (rec *Recorder) Target(name string) *log4.Log4Target {
+	target := rec.log4.Target(name)
+	if target == nil {
+		target = rec.log4.Target("root")
+	}
+	return target
+}
+
+// Records returns a copy of the records logged so far.
+func (rec *Recorder) Records() []Record {
+	rec.appender.mutex.Lock()
+	defer rec.appender.mutex.Unlock()
+	return append([]Record(nil), rec.appender.records...)
+}
+
+// Reset drops the records logged so far.
+func (rec *Recorder) Reset() {
+	rec.appender.mutex.Lock()
+	defer rec.appender.mutex.Unlock()
+	rec.appender.records = nil
+}
+
+// Contains reports whether a record of level and target has substr in its
+// message, an empty target matches every target.
+func (rec *Recorder) Contains(level log4.Level, target string, substr string) bool {
+	return len(rec.Find(level, target, substr)) > 0
+}
+
+// Find returns the records Contains matches.
+func (rec *Recorder) Find(level log4.Level, target string, substr string) []Record {
+	var records []Record
+	for _, record := range rec.Records() {
+		if record.Level != level {
+			continue
+		}
+		if len(target) > 0 && record.Target != target {
+			continue
+		}
+		if strings.Contains(record.Message, substr) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
@@ -0,0 +1,130 @@
+package log4test
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yefy/log4go/ee"
+	"github.com/yefy/log4go/log4"
+)
+
+func TestRecorderCaptures(t *testing.T) {
+	rec := New(t, WithLoggers("db"))
+
+	rec.Target("db").Warn("slow query:%v", 42)
+	rec.Target("").Info("started")
+	rec.Target("db").ErrorErr(ee.NewE(errors.New("eof"), "read").With("table", "users"), "query failed")
+	rec.Target("nope").Debug("falls back to root")
+
+	records := rec.Records()
+	if len(records) != 4 {
+		t.Fatalf("records:%+v", records)
+	}
+	first := records[0]
+	if first.Level != log4.WARNING || first.Target != "db" || first.Message != "slow query:42" {
+		t.Errorf("first:%+v", first)
+	}
+	if !strings.HasPrefix(first.Text, "[db] [WARN] (") || !strings.HasSuffix(first.Text, ") slow query:42") {
+		t.Errorf("text:%q", first.Text)
+	}
+	if !strings.Contains(first.Source, "log4test_test.go:") {
+		t.Errorf("source:%q", first.Source)
+	}
+	if records[3].Target != "root" || records[3].Level != log4.DEBUG {
+		t.Errorf("fallback to root:%+v", records[3])
+	}
+
+	failed := rec.Find(log4.ERROR, "db", "query failed")
+	if len(failed) != 1 {
+		t.Fatalf("Find:%+v", failed)
+	}
+	if len(failed[0].Fields) != 1 || failed[0].Fields[0] != (ee.Attr{Key: "table", Value: "users"}) {
+		t.Errorf("fields:%+v", failed[0].Fields)
+	}
+	if len(failed[0].ErrFrames) != 2 || failed[0].ErrFrames[1].Message != "eof" {
+		t.Errorf("frames:%+v", failed[0].ErrFrames)
+	}
+
+	if !rec.Contains(log4.INFO, "", "start") || rec.Contains(log4.INFO, "db", "start") || rec.Contains(log4.WARNING, "", "started") {
+		t.Error("Contains matched the wrong records")
+	}
+
+	rec.Reset()
+	if len(rec.Records()) != 0 {
+		t.Fatalf("records after Reset:%+v", rec.Records())
+	}
+	rec.Target("db").Info("after reset")
+	if records := rec.Records(); len(records) != 1 || records[0].Message != "after reset" {
+		t.Fatalf("records after Reset:%+v", records)
+	}
+}
+
+func TestRecorderLevel(t *testing.T) {
+	rec := New(t, WithLevel("warn"))
+	rec.Target("").Info("dropped")
+	rec.Target("").Error("kept")
+	records := rec.Records()
+	if len(records) != 1 || records[0].Message != "kept" {
+		t.Fatalf("records:%+v", records)
+	}
+}
+
+// logTB records what the mirror writes.
+type logTB struct {
+	testing.TB
+	mutex sync.Mutex
+	logs  []string
+}
+
+func (tb *logTB) Log(args ...any) {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	tb.logs = append(tb.logs, fmt.Sprint(args...))
+}
+
+func TestRecorderMirror(t *testing.T) {
+	tb := &logTB{TB: t}
+	rec := New(tb, WithMirror())
+	rec.Target("").Warn("mirrored")
+	if len(tb.logs) != 1 || !strings.HasSuffix(tb.logs[0], ") mirrored") {
+		t.Fatalf("logs:%q", tb.logs)
+	}
+}
+
+// TestRecorderNoReloadGoroutine checks that a Log4 run without a file starts
+// no reload goroutine and that closing it leaves none of its goroutines.
+func TestRecorderNoReloadGoroutine(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		t.Run("recorder", func(t *testing.T) {
+			rec := New(t, WithLoggers("a", "b"))
+			rec.Target("a").Info("x")
+		})
+	}
+
+	// a refresh rate without a file is not reloaded either
+	l4 := log4.NewLog4("")
+	err := l4.Run(&log4.Log4Config{RefreshRate: 1, Root: log4.Log4ConfigLogger{Level: "info"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("goroutines %v before, %v with a Log4 running", before, n)
+	}
+	l4.Close(true)
+
+	after := runtime.NumGoroutine()
+	for deadline := time.Now().Add(time.Second); after > before && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		after = runtime.NumGoroutine()
+	}
+	if after > before {
+		buf := make([]byte, 1<<16)
+		t.Fatalf("goroutines %v before, %v after:\n%s", before, after, buf[:runtime.Stack(buf, true)])
+	}
+}